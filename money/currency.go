@@ -0,0 +1,31 @@
+package money
+
+import "strings"
+
+// MinorUnit is a currency quoted in a fraction of another, London quotes in pence (GBX) and not in pounds.
+type MinorUnit struct {
+	Currency string
+	PerUnit  int64
+}
+
+// MinorUnits are the minor unit codes the providers use. Some write them with the last letter in lower case, GBp or ZAc.
+var MinorUnits = map[string]MinorUnit{
+	"GBX": {"GBP", 100},
+	"GBp": {"GBP", 100},
+	"ZAC": {"ZAR", 100},
+	"ZAc": {"ZAR", 100},
+	"ILA": {"ILS", 100},
+}
+
+// Normalize is the ISO code of the currency a quote in currency is really in, and how many of the quote make one of it.
+// GBX and GBp are 100 to the GBP, the currencies that are not a minor unit are 1 to themselves.
+func Normalize(currency string) (string, int64) {
+	currency = strings.TrimSpace(currency)
+	if unit, isIn := MinorUnits[currency]; isIn {
+		return unit.Currency, unit.PerUnit
+	}
+	if unit, isIn := MinorUnits[strings.ToUpper(currency)]; isIn {
+		return unit.Currency, unit.PerUnit
+	}
+	return strings.ToUpper(currency), 1
+}
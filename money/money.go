@@ -0,0 +1,269 @@
+// Package money does exact decimal arithmetic on amounts, with explicit rounding rules per currency.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of decimals an Amount keeps, enough for prices of fractions of a cent and per share dividends.
+const Scale = 8
+
+const unit = 100000000
+
+// Amount is a decimal number kept as an integer count of 10^-Scale, so it holds up to about ±92 billion.
+// An operation whose result is past that panics with ErrOutOfRange rather than wrap around.
+type Amount int64
+
+// Zero is the zero Amount, the zero value works as well.
+const Zero Amount = 0
+
+// ErrOutOfRange is the error of an amount too large for an Amount.
+var ErrOutOfRange = errors.New("money: amount out of range")
+
+// FromInt is n units, e.g. FromInt(5) is 5.00.
+func FromInt(n int64) Amount {
+	if n > math.MaxInt64/unit || n < math.MinInt64/unit {
+		panic(fmt.Errorf("%w: %d", ErrOutOfRange, n))
+	}
+	return Amount(n * unit)
+}
+
+// FromFloat is the decimal a float64 prints as, so FromFloat(381.64) is exactly 381.64.
+// It panics when f is not a number or out of range, see ParseFloat.
+func FromFloat(f float64) Amount {
+	a, err := ParseFloat(f)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// ParseFloat is FromFloat with an error when f is not a number or is out of range.
+func ParseFloat(f float64) (Amount, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("money: invalid amount %v", f)
+	}
+	// the decimals past Scale are rounded half up by Parse
+	return Parse(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// Parse reads a decimal like -12.345, with one optional sign, decimals past Scale are rounded half up.
+func Parse(s string) (Amount, error) {
+	str := strings.TrimSpace(s)
+	if str == "" {
+		return 0, errors.New("money: empty amount")
+	}
+	negative := strings.HasPrefix(str, "-")
+	if negative || strings.HasPrefix(str, "+") {
+		str = str[1:]
+	}
+	whole, fraction := str, ""
+	if i := strings.Index(str, "."); i >= 0 {
+		whole, fraction = str[:i], str[i+1:]
+	}
+	digits := whole + fraction
+	if digits == "" {
+		return 0, fmt.Errorf("money: invalid amount %q", s)
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("money: invalid amount %q", s)
+		}
+	}
+	n, isOk := new(big.Int).SetString(digits, 10)
+	if !isOk {
+		return 0, fmt.Errorf("money: invalid amount %q", s)
+	}
+	if len(fraction) > Scale {
+		n = roundBig(n, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(len(fraction)-Scale)), nil), HalfUp)
+	} else {
+		n.Mul(n, big.NewInt(pow10(Scale-len(fraction))))
+	}
+	if negative {
+		n.Neg(n)
+	}
+	if !n.IsInt64() {
+		return 0, fmt.Errorf("%w: %q", ErrOutOfRange, s)
+	}
+	return Amount(n.Int64()), nil
+}
+
+func (a Amount) Add(b Amount) Amount {
+	return a + b
+}
+
+func (a Amount) Sub(b Amount) Amount {
+	return a - b
+}
+
+func (a Amount) Neg() Amount {
+	return -a
+}
+
+// Mul is a * b rounded half up to Scale decimals.
+func (a Amount) Mul(b Amount) Amount {
+	n := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(int64(b)))
+	return Amount(round(n, big.NewInt(unit), HalfUp))
+}
+
+// MulFloat multiplies by a quantity, like a number of shares, taken as the decimal it prints as.
+func (a Amount) MulFloat(quantity float64) Amount {
+	return a.Mul(FromFloat(quantity))
+}
+
+// Div is a / b rounded half up to Scale decimals, zero when b is zero.
+func (a Amount) Div(b Amount) Amount {
+	if b == 0 {
+		return 0
+	}
+	n := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(unit))
+	return Amount(round(n, big.NewInt(int64(b)), HalfUp))
+}
+
+// DivFloat divides by a quantity, like a number of shares.
+func (a Amount) DivFloat(quantity float64) Amount {
+	return a.Div(FromFloat(quantity))
+}
+
+func (a Amount) Float64() float64 {
+	f, _ := strconv.ParseFloat(a.String(), 64)
+	return f
+}
+
+func (a Amount) IsZero() bool {
+	return a == 0
+}
+
+func (a Amount) Sign() int {
+	switch {
+	case a < 0:
+		return -1
+	case a > 0:
+		return 1
+	}
+	return 0
+}
+
+// Round keeps the decimals given, rounding by mode.
+func (a Amount) Round(decimals int, mode RoundingMode) Amount {
+	if decimals >= Scale {
+		return a
+	}
+	step := big.NewInt(pow10(Scale - decimals))
+	n := roundBig(big.NewInt(int64(a)), step, mode)
+	return Amount(checked(n.Mul(n, step)))
+}
+
+// RoundTo rounds by the rule of the currency.
+func (a Amount) RoundTo(currency string) Amount {
+	rule := RuleFor(currency)
+	return a.Round(rule.Decimals, rule.Mode)
+}
+
+// String writes every significant decimal, 12.5 or 0.00125.
+func (a Amount) String() string {
+	str := a.StringFixed(Scale)
+	str = strings.TrimRight(str, "0")
+	return strings.TrimSuffix(str, ".")
+}
+
+// StringFixed rounds half up and writes exactly decimals decimals.
+func (a Amount) StringFixed(decimals int) string {
+	if decimals > Scale {
+		decimals = Scale
+	}
+	rounded := int64(a.Round(decimals, HalfUp))
+	sign := ""
+	if rounded < 0 {
+		sign = "-"
+		rounded = -rounded
+	}
+	whole := rounded / unit
+	fraction := fmt.Sprintf("%08d", rounded%unit)[:decimals]
+	if decimals == 0 {
+		return sign + strconv.FormatInt(whole, 10)
+	}
+	return sign + strconv.FormatInt(whole, 10) + "." + fraction
+}
+
+// In writes the amount rounded by the rule of the currency, with the decimals of the currency.
+func (a Amount) In(currency string) string {
+	rule := RuleFor(currency)
+	return a.Round(rule.Decimals, rule.Mode).StringFixed(rule.Decimals)
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON takes the number as written in the file, "381.64" stays 381.64 without going through a float.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	str := strings.Trim(string(data), `"`)
+	if str == "null" {
+		return nil
+	}
+	parsed, err := Parse(str)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Sum adds up amounts.
+func Sum(amounts ...Amount) Amount {
+	total := Zero
+	for _, a := range amounts {
+		total += a
+	}
+	return total
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
+
+// round divides n by step and rounds the quotient by mode, it panics when the quotient is out of range.
+func round(n *big.Int, step *big.Int, mode RoundingMode) int64 {
+	return checked(roundBig(n, step, mode))
+}
+
+// checked is n, which has to fit an Amount.
+func checked(n *big.Int) int64 {
+	if !n.IsInt64() {
+		panic(fmt.Errorf("%w: %s", ErrOutOfRange, n.String()))
+	}
+	return n.Int64()
+}
+
+// roundBig divides n by divisor and rounds the quotient by mode.
+func roundBig(n *big.Int, divisor *big.Int, mode RoundingMode) *big.Int {
+	negative := (n.Sign() < 0) != (divisor.Sign() < 0)
+	quotient, remainder := new(big.Int).QuoRem(new(big.Int).Abs(n), new(big.Int).Abs(divisor), new(big.Int))
+	twice := new(big.Int).Mul(remainder, big.NewInt(2))
+	half := twice.Cmp(new(big.Int).Abs(divisor))
+	switch mode {
+	case HalfUp:
+		if half >= 0 {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	case HalfEven:
+		if half > 0 || half == 0 && quotient.Bit(0) == 1 {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	case Down:
+	}
+	if negative {
+		quotient.Neg(quotient)
+	}
+	return quotient
+}
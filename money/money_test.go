@@ -0,0 +1,141 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+		ok   bool
+	}{
+		{"381.64", 38164000000, true},
+		{"-12.345", -1234500000, true},
+		{"+3", 300000000, true},
+		{" 7 ", 700000000, true},
+		{".5", 50000000, true},
+		{"5.", 500000000, true},
+		{"0.000000015", 2, true},
+		{"-0.000000015", -2, true},
+		{"92233720368.54775807", math.MaxInt64, true},
+		{"92233720368.54775808", 0, false},
+		{"", 0, false},
+		{"-", 0, false},
+		{"+", 0, false},
+		{".", 0, false},
+		{"+-3", 0, false},
+		{"--3", 0, false},
+		{"3-", 0, false},
+		{"1.2.3", 0, false},
+		{"1e5", 0, false},
+	}
+	for _, test := range tests {
+		got, err := Parse(test.in)
+		if (err == nil) != test.ok {
+			t.Errorf("Parse(%q) error %v, want ok %v", test.in, err, test.ok)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Parse(%q) = %s, want %s", test.in, got, test.want)
+		}
+	}
+}
+
+func TestParseFloat(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want string
+		ok   bool
+	}{
+		{381.64, "381.64", true},
+		{-0.1, "-0.1", true},
+		{1e-20, "0", true},
+		{0.123456789, "0.12345679", true},
+		{9e10, "90000000000", true},
+		{1e11, "", false},
+		{-1e11, "", false},
+		{math.NaN(), "", false},
+		{math.Inf(1), "", false},
+	}
+	for _, test := range tests {
+		got, err := ParseFloat(test.in)
+		if (err == nil) != test.ok {
+			t.Errorf("ParseFloat(%v) error %v, want ok %v", test.in, err, test.ok)
+			continue
+		}
+		if test.ok && got.String() != test.want {
+			t.Errorf("ParseFloat(%v) = %s, want %s", test.in, got, test.want)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Amount
+		want string
+	}{
+		{"Mul", FromFloat(19.99).MulFloat(3), "59.97"},
+		{"Mul rounds half up", FromFloat(0.00000001).Mul(FromFloat(0.5)), "0.00000001"},
+		{"Mul negative", FromFloat(-0.00000001).Mul(FromFloat(0.5)), "-0.00000001"},
+		{"Div", FromInt(10).DivFloat(3), "3.33333333"},
+		{"Div by zero", FromInt(10).Div(Zero), "0"},
+		{"Round half up", FromFloat(0.125).Round(2, HalfUp), "0.13"},
+		{"Round half up negative", FromFloat(-0.125).Round(2, HalfUp), "-0.13"},
+		{"Round half even", FromFloat(0.125).Round(2, HalfEven), "0.12"},
+		{"Round half even odd", FromFloat(0.135).Round(2, HalfEven), "0.14"},
+		{"Round down", FromFloat(0.129).Round(2, Down), "0.12"},
+		{"RoundTo JPY", FromFloat(1234.5).RoundTo("JPY"), "1235"},
+	}
+	for _, test := range tests {
+		if test.got.String() != test.want {
+			t.Errorf("%s = %s, want %s", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestIn(t *testing.T) {
+	tests := []struct {
+		amount   Amount
+		currency string
+		want     string
+	}{
+		{FromFloat(1.005), "CAD", "1.01"},
+		{FromFloat(-1.005), "USD", "-1.01"},
+		{FromFloat(1234.5), "JPY", "1235"},
+		{FromInt(3), "XYZ", "3.00"},
+	}
+	for _, test := range tests {
+		if got := test.amount.In(test.currency); got != test.want {
+			t.Errorf("%s.In(%s) = %s, want %s", test.amount, test.currency, got, test.want)
+		}
+	}
+}
+
+func TestOutOfRangePanics(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func()
+	}{
+		{"FromFloat", func() { FromFloat(1e11) }},
+		{"FromInt", func() { FromInt(1e11) }},
+		{"Mul", func() { FromInt(1e6).Mul(FromInt(1e6)) }},
+		{"MulFloat", func() { FromInt(1e9).MulFloat(1000) }},
+		{"Div", func() { FromInt(1e9).Div(FromFloat(0.001)) }},
+		{"Round", func() { Amount(math.MaxInt64).Round(0, HalfUp) }},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				err, isErr := recover().(error)
+				if !isErr || !errors.Is(err, ErrOutOfRange) {
+					t.Errorf("%s did not panic with ErrOutOfRange: %v", test.name, err)
+				}
+			}()
+			test.f()
+		}()
+	}
+}
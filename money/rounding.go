@@ -0,0 +1,49 @@
+package money
+
+import "strings"
+
+type RoundingMode int
+
+const (
+	// HalfUp rounds halves away from zero, 0.125 is 0.13 and -0.125 is -0.13
+	HalfUp RoundingMode = iota
+	// HalfEven rounds halves to the even digit, 0.125 is 0.12 and 0.135 is 0.14
+	HalfEven
+	// Down drops the extra decimals
+	Down
+)
+
+// Rule is how amounts of a currency are rounded when they are paid or reported.
+type Rule struct {
+	Decimals int
+	Mode     RoundingMode
+}
+
+// DefaultRule is used for the currencies not in Rules.
+var DefaultRule = Rule{2, HalfUp}
+
+// Rules are the rounding of the currencies, by ISO code. The brokers pay and report to the cent, rounding half up.
+var Rules = map[string]Rule{
+	"CAD": {2, HalfUp},
+	"USD": {2, HalfUp},
+	"EUR": {2, HalfUp},
+	"GBP": {2, HalfUp},
+	"CHF": {2, HalfUp},
+	"JPY": {0, HalfUp},
+	"AUD": {2, HalfUp},
+	"NZD": {2, HalfUp},
+	"HKD": {2, HalfUp},
+	"SEK": {2, HalfUp},
+	"NOK": {2, HalfUp},
+	"DKK": {2, HalfUp},
+	"ZAR": {2, HalfUp},
+	"ILS": {2, HalfUp},
+	"KRW": {0, HalfUp},
+}
+
+func RuleFor(currency string) Rule {
+	if rule, isIn := Rules[strings.ToUpper(currency)]; isIn {
+		return rule
+	}
+	return DefaultRule
+}
@@ -0,0 +1,358 @@
+package portfolio
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kmorin72/stock/money"
+)
+
+// Disposition is one sell with the adjusted cost base of the shares it gives up, as the CRA computes the gain.
+type Disposition struct {
+	Symbol            string
+	TxID              string
+	Date              string
+	Quantity          float64
+	Proceeds          money.Amount
+	ACB               money.Amount
+	Outlays           money.Amount
+	Gain              money.Amount
+	Denied            money.Amount
+	Lost              money.Amount
+	MovedTo           []ACBAdjustment
+	ReplacedBy        []string
+	YearOfAcquisition string
+}
+
+// ACBResult is the adjusted cost base of a stock after its whole ledger and the dispositions that led to it,
+// in Currency, the one the gains are reported in.
+type ACBResult struct {
+	Account      string
+	Symbol       string
+	Name         string
+	Currency     string
+	Shares       float64
+	ACB          money.Amount
+	Dispositions []Disposition
+}
+
+// CapitalGain is the line of a symbol for one tax year, the sum of its dispositions of that year.
+type CapitalGain struct {
+	Year              string
+	Account           string
+	Symbol            string
+	Name              string
+	Currency          string
+	Quantity          float64
+	YearOfAcquisition string
+	Proceeds          money.Amount
+	ACB               money.Amount
+	Outlays           money.Amount
+	Gain              money.Amount
+}
+
+// ComputeACB replays the buys and sells of the stock in date order. A buy adds its cost and commission to the
+// ACB of the pool of shares, a sell takes out the average cost of the shares sold and its commission and fees are outlays.
+// The year of acquisition is the year the shares sold started to be held, since the position was last empty.
+// A superficial loss is denied and added to the ACB of the shares that replaced the ones sold, the buys of holdings
+// in every account count, see superficialShares. The part replaced in another account is in MovedTo, for the
+// adjustments of that account, and the part replaced in a registered account is Lost.
+// The amounts are in the currency of c at the rate of the trade date, as the CRA wants them in CAD, or in the
+// currency of the stock when c is nil. It fails when a trade has no rate.
+func ComputeACB(stock Stock, c *Converter, account string, holdings Holdings, adjustments []ACBAdjustment) (ACBResult, error) {
+	currency := stock.Currency
+	if c != nil {
+		currency = c.Currency
+	}
+	convert := func(amount money.Amount, date string) (money.Amount, error) {
+		amount = amount.RoundTo(stock.Currency)
+		if currency == stock.Currency {
+			return amount, nil
+		}
+		rate, err := c.Rate(stock.Currency, date)
+		if err != nil {
+			return money.Zero, fmt.Errorf("%s: %s", stock.Symbol, err.Error())
+		}
+		return amount.MulFloat(rate).RoundTo(currency), nil
+	}
+	result := ACBResult{Symbol: stock.Symbol, Name: stock.Name, Currency: currency}
+	txs := txByID(stock)
+
+	acquired := ""
+	pending := money.Zero
+	// the losses moved from the other accounts are added once their day is over
+	own := []ACBAdjustment{}
+	for _, adjustment := range adjustments {
+		if adjustment.Account == account {
+			own = append(own, adjustment)
+		}
+	}
+	sort.SliceStable(own, func(i, j int) bool { return own[i].Date < own[j].Date })
+	adjust := func(before string) {
+		for len(own) > 0 && (before == "" || own[0].Date < before) {
+			if result.Shares > 0 {
+				result.ACB += own[0].Amount
+			} else {
+				pending += own[0].Amount
+			}
+			own = own[1:]
+		}
+	}
+	for _, date := range TimelineDates(stock) {
+		adjust(date)
+		for _, event := range stock.Timeline[date] {
+			tx, isTx := txs[event.TxID]
+			if !isTx {
+				continue
+			}
+			amount, err := convert(tx.Price.MulFloat(tx.Quantity), date)
+			if err != nil {
+				return result, err
+			}
+			costs, err := convert(tx.Costs(), date)
+			if err != nil {
+				return result, err
+			}
+			switch event.Type {
+			case "buy":
+				if result.Shares <= 0 {
+					acquired = date[:4]
+				} else if acquired != date[:4] {
+					acquired = "various"
+				}
+				result.ACB += amount + costs + pending
+				pending = money.Zero
+				result.Shares = roundShares(result.Shares + tx.Quantity)
+			case "sell":
+				d := Disposition{Symbol: stock.Symbol, TxID: tx.ID, Date: date, Quantity: tx.Quantity, YearOfAcquisition: acquired}
+				d.Proceeds = amount
+				d.Outlays = costs
+				d.ACB = result.ACB
+				if tx.Quantity < result.Shares {
+					d.ACB = result.ACB.MulFloat(tx.Quantity).DivFloat(result.Shares).RoundTo(currency)
+				}
+				d.Gain = d.Proceeds - d.ACB - d.Outlays
+				d.Denied = deniedLoss(holdings, account, tx, &d, currency)
+				d.Gain += d.Denied
+				result.ACB -= d.ACB
+				result.Shares = roundShares(result.Shares - tx.Quantity)
+				if result.Shares <= 0 {
+					// the shares that replace them are bought later on
+					result.ACB = money.Zero
+					pending += d.kept()
+				} else {
+					result.ACB += d.kept()
+				}
+				result.Dispositions = append(result.Dispositions, d)
+			}
+		}
+	}
+	adjust("")
+	return result, nil
+}
+
+// txByID are the buys and sells of the stock by their ID, to find the Tx of a timeline event.
+func txByID(stock Stock) map[string]Tx {
+	txs := make(map[string]Tx)
+	for _, tx := range stock.Buys {
+		txs[tx.ID] = tx
+	}
+	for _, tx := range stock.Sells {
+		txs[tx.ID] = tx
+	}
+	return txs
+}
+
+// ComputeACB is the ACBResult of every stock, by symbol, in the currency of c. Only the buys of the account
+// make a loss superficial, see the one of Household for all of them. The stocks that could not be converted are
+// left out and in the map, with why.
+func (p *Portfolio) ComputeACB(c *Converter) ([]ACBResult, map[string]string) {
+	results := []ACBResult{}
+	missing := make(map[string]string)
+	for _, symbol := range p.Symbols() {
+		result, err := ComputeACB(p.Stocks[symbol], c, p.Account, StockHoldings(p.Account, p.Stocks[symbol]), nil)
+		if err != nil {
+			missing[accountSymbol(p.Account, symbol)] = err.Error()
+			continue
+		}
+		result.Account = p.Account
+		results = append(results, result)
+	}
+	return results, missing
+}
+
+// ComputeACB is the ACBResult of every stock of the taxable accounts, by account and symbol, in the currency of c.
+// The buys of every account, the registered ones too, make a loss superficial, and the loss denied by a buy of
+// another taxable account goes to the ACB of that account. As the loss moved can change the next losses of that
+// account, the accounts of a symbol are computed again until the moves settle. The stocks that could not be
+// converted are left out and in the map, with why.
+func (h *Household) ComputeACB(c *Converter, taxable func(account string) bool) ([]ACBResult, map[string]string) {
+	registered := func(account string) bool {
+		return !taxable(account)
+	}
+	byAccount := make(map[string][]ACBResult)
+	missing := make(map[string]string)
+	for _, symbol := range h.Symbols() {
+		holdings := h.Holdings(symbol, registered)
+		adjustments := []ACBAdjustment{}
+		var results map[string]ACBResult
+		var symbolMissing map[string]string
+		for pass := 0; pass < maxACBPasses; pass++ {
+			results = make(map[string]ACBResult)
+			symbolMissing = make(map[string]string)
+			moved := []ACBAdjustment{}
+			for _, name := range h.Names() {
+				stock, isIn := h.Accounts[name].Stocks[symbol]
+				if !isIn || !taxable(name) {
+					continue
+				}
+				result, err := ComputeACB(stock, c, name, holdings, adjustments)
+				if err != nil {
+					symbolMissing[accountSymbol(name, symbol)] = err.Error()
+					continue
+				}
+				result.Account = name
+				results[name] = result
+				for _, d := range result.Dispositions {
+					moved = append(moved, d.MovedTo...)
+				}
+			}
+			if sameAdjustments(moved, adjustments) {
+				break
+			}
+			adjustments = moved
+		}
+		for name, result := range results {
+			byAccount[name] = append(byAccount[name], result)
+		}
+		for name, why := range symbolMissing {
+			missing[name] = why
+		}
+	}
+	results := []ACBResult{}
+	for _, name := range h.Names() {
+		results = append(results, byAccount[name]...)
+	}
+	return results, missing
+}
+
+// maxACBPasses bounds how many times the accounts of a symbol are computed for the losses moved between them.
+const maxACBPasses = 10
+
+func sameAdjustments(a []ACBAdjustment, b []ACBAdjustment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// CapitalGains sums the dispositions of the results by year and symbol, sorted the same way.
+func CapitalGains(results []ACBResult) []CapitalGain {
+	gains := []CapitalGain{}
+	for _, result := range results {
+		byYear := make(map[string]*CapitalGain)
+		years := []string{}
+		for _, d := range result.Dispositions {
+			year := d.Date[:4]
+			gain, isIn := byYear[year]
+			if !isIn {
+				gain = &CapitalGain{Year: year, Account: result.Account, Symbol: result.Symbol, Name: result.Name, Currency: result.Currency, YearOfAcquisition: d.YearOfAcquisition}
+				byYear[year] = gain
+				years = append(years, year)
+			} else if gain.YearOfAcquisition != d.YearOfAcquisition {
+				gain.YearOfAcquisition = "various"
+			}
+			gain.Quantity = roundShares(gain.Quantity + d.Quantity)
+			gain.Proceeds += d.Proceeds
+			gain.ACB += d.ACB
+			gain.Outlays += d.Outlays
+			gain.Gain += d.Gain
+		}
+		for _, year := range years {
+			gains = append(gains, *byYear[year])
+		}
+	}
+	sort.SliceStable(gains, func(i, j int) bool {
+		return gains[i].Year < gains[j].Year
+	})
+	return gains
+}
+
+// GetCapitalGainsString lists the gains of year, every year when it is empty, with the total of each currency.
+func GetCapitalGainsString(gains []CapitalGain, year string) string {
+	str := ""
+	current := ""
+	totals := make(map[string]money.Amount)
+	flush := func() {
+		for _, currency := range sortedKeys(totals) {
+			str += fmt.Sprintf("  %-10s %12s %12s %12s %12s\n", "Total "+currency, "", "", "", totals[currency].In(currency))
+		}
+		totals = make(map[string]money.Amount)
+	}
+	for _, gain := range gains {
+		if year != "" && gain.Year != year {
+			continue
+		}
+		if gain.Year != current {
+			flush()
+			current = gain.Year
+			str += "\nCapital gains " + current + "\n"
+			str += fmt.Sprintf("  %-10s %12s %12s %12s %12s\n", "Symbol", "Proceeds", "ACB", "Outlays", "Gain")
+		}
+		str += fmt.Sprintf("  %-10s %12s %12s %12s %12s\n", accountSymbol(gain.Account, gain.Symbol), gain.Proceeds.In(gain.Currency), gain.ACB.In(gain.Currency), gain.Outlays.In(gain.Currency), gain.Gain.In(gain.Currency))
+		totals[gain.Currency] += gain.Gain
+	}
+	flush()
+	if str == "" {
+		str = "\nNo capital gains\n"
+	}
+	return str
+}
+
+// GetACBMissingString lists the stocks left out of the capital gains, with why.
+func GetACBMissingString(missing map[string]string) string {
+	if len(missing) == 0 {
+		return ""
+	}
+	symbols := []string{}
+	for symbol := range missing {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	str := "\nNot in the capital gains:\n"
+	for _, symbol := range symbols {
+		str += fmt.Sprintf("    %-12s : %s\n", symbol, missing[symbol])
+	}
+	return str
+}
+
+// accountSymbol is the symbol with the account it is held in, when there is one.
+func accountSymbol(account string, symbol string) string {
+	if account == "" {
+		return symbol
+	}
+	return account + "/" + symbol
+}
+
+// GetSchedule3CSV writes the gains of year in the columns of the publicly traded shares part of Schedule 3,
+// the gains have to be in CAD.
+func GetSchedule3CSV(gains []CapitalGain, year string) string {
+	str := "Number of units, Name of corporation and class of shares, Year of acquisition, Proceeds of disposition, Adjusted cost base, Outlays and expenses, Gain (or loss), Account\n"
+	for _, gain := range gains {
+		if gain.Year != year {
+			continue
+		}
+		name := gain.Name
+		if name != gain.Symbol {
+			name += " (" + gain.Symbol + ")"
+		}
+		str += fmt.Sprintf("%s, %q, %s, %s, %s, %s, %s, %s\n", FormatShares(gain.Quantity), name, gain.YearOfAcquisition,
+			gain.Proceeds.In(gain.Currency), gain.ACB.In(gain.Currency), gain.Outlays.In(gain.Currency), gain.Gain.In(gain.Currency), gain.Account)
+	}
+	return str
+}
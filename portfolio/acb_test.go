@@ -0,0 +1,128 @@
+package portfolio
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kmorin72/stock/money"
+	"github.com/kmorin72/stock/provider"
+)
+
+// testRates is a source of exchange rates with the histories of the pairs, most recent day first.
+type testRates map[string][]provider.Day
+
+func (r testRates) Name() string {
+	return "test"
+}
+
+func (r testRates) Current(symbol string) (provider.Quote, error) {
+	return provider.Quote{}, fmt.Errorf("no quote of %s", symbol)
+}
+
+func (r testRates) History(symbol string) (provider.History, error) {
+	days, isIn := r[symbol]
+	if !isIn {
+		return provider.History{}, fmt.Errorf("no history of %s", symbol)
+	}
+	return provider.History{Symbol: symbol, Days: days}, nil
+}
+
+// addTx adds a buy or sell with its commission and fees to the stock.
+func addTx(stock *Stock, kind string, date string, quantity float64, price float64, commission float64, fees float64) {
+	id := stock.Symbol + ":" + date
+	tx := Tx{ID: id, Date: date, Quantity: quantity, Price: money.FromFloat(price), Commission: money.FromFloat(commission), Fees: money.FromFloat(fees)}
+	if kind == "buy" {
+		stock.Buys = append(stock.Buys, tx)
+	} else {
+		stock.Sells = append(stock.Sells, tx)
+	}
+	addStockEvent(*stock, date, StockEvent{kind, quantity, tx.Price, 0, 0, id, tx.Costs()})
+}
+
+func TestComputeACBWithCosts(t *testing.T) {
+	stock := newStock("BCE.TO")
+	addTx(&stock, "buy", "2018-03-01", 100, 10, 9.99, 0.01)
+	addTx(&stock, "buy", "2019-02-01", 50, 12, 5, 0)
+	addTx(&stock, "sell", "2019-06-03", 60, 15, 10, 0.5)
+	addTx(&stock, "sell", "2020-01-06", 90, 11, 10, 0)
+
+	result, err := ComputeACB(stock, nil, "", StockHoldings("", stock), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Currency != "CAD" || result.Shares != 0 || !result.ACB.IsZero() {
+		t.Errorf("ends with %v shares and an ACB of %s %s, want none", result.Shares, result.ACB.In(result.Currency), result.Currency)
+	}
+	tests := []struct {
+		proceeds, acb, outlays, gain string
+		acquired                     string
+	}{
+		// 1010 + 605 for 150 shares, 60 of them
+		{"900.00", "646.00", "10.50", "243.50", "various"},
+		{"990.00", "969.00", "10.00", "11.00", "various"},
+	}
+	if len(result.Dispositions) != len(tests) {
+		t.Fatalf("%d dispositions, want %d", len(result.Dispositions), len(tests))
+	}
+	for i, test := range tests {
+		d := result.Dispositions[i]
+		got := []string{d.Proceeds.In("CAD"), d.ACB.In("CAD"), d.Outlays.In("CAD"), d.Gain.In("CAD"), d.YearOfAcquisition}
+		want := []string{test.proceeds, test.acb, test.outlays, test.gain, test.acquired}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("sell %s is %v, want %v", d.Date, got, want)
+		}
+	}
+
+	gains := CapitalGains([]ACBResult{result})
+	if len(gains) != 2 || gains[0].Year != "2019" || gains[1].Year != "2020" {
+		t.Fatalf("gains are %+v, want 2019 and 2020", gains)
+	}
+	if gains[0].Quantity != 60 || gains[0].Gain != money.FromFloat(243.5) {
+		t.Errorf("2019 is %v shares for %s, want 60 for 243.50", gains[0].Quantity, gains[0].Gain.In("CAD"))
+	}
+}
+
+func TestComputeACBInCADAtTheTradeDate(t *testing.T) {
+	rates := testRates{"USDCAD": {{Date: "2018-06-01", Close: 1.30}, {Date: "2018-01-02", Close: 1.25}}}
+	c := NewConverter("CAD", rates)
+	stock := newStock("AAPL")
+	addTx(&stock, "buy", "2018-01-03", 100, 10, 5, 0)
+	addTx(&stock, "sell", "2018-06-04", 100, 12, 5, 0)
+
+	result, err := ComputeACB(stock, c, "", StockHoldings("", stock), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Currency != "CAD" {
+		t.Errorf("currency is %s, want CAD", result.Currency)
+	}
+	d := result.Dispositions[0]
+	// bought for 1005 USD at 1.25, sold for 1195 USD at 1.30
+	got := []string{d.Proceeds.In("CAD"), d.ACB.In("CAD"), d.Outlays.In("CAD"), d.Gain.In("CAD")}
+	want := []string{"1560.00", "1256.25", "6.50", "297.25"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("sell is %v, want %v", got, want)
+	}
+	if csv := GetSchedule3CSV(CapitalGains([]ACBResult{result}), "2018"); csv != GetSchedule3CSV(nil, "")+`100, "AAPL", 2018, 1560.00, 1256.25, 6.50, 297.25, `+"\n" {
+		t.Errorf("schedule 3 is\n%s", csv)
+	}
+}
+
+func TestComputeACBWithoutRate(t *testing.T) {
+	p := New()
+	p.Account = "cash"
+	stock := p.Stock("AAPL")
+	addTx(&stock, "buy", "2017-12-01", 100, 10, 0, 0)
+	p.Stocks["AAPL"] = stock
+	bce := p.Stock("BCE.TO")
+	addTx(&bce, "buy", "2017-12-01", 100, 10, 0, 0)
+	p.Stocks["BCE.TO"] = bce
+
+	results, missing := p.ComputeACB(NewConverter("CAD", testRates{"USDCAD": {{Date: "2018-01-02", Close: 1.25}}}))
+	if len(results) != 1 || results[0].Symbol != "BCE.TO" {
+		t.Errorf("results are %+v, want BCE.TO alone", results)
+	}
+	if _, isIn := missing["cash/AAPL"]; !isIn || len(missing) != 1 {
+		t.Errorf("missing is %v, want cash/AAPL", missing)
+	}
+}
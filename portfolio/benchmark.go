@@ -0,0 +1,137 @@
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"time"
+
+	"github.com/kmorin72/stock/money"
+	"github.com/kmorin72/stock/provider"
+)
+
+// Benchmark is an index or ETF the portfolio is compared to, with the price and total return of the ROI windows.
+// Its dividends are the ones of data/dividends, like for a stock.
+type Benchmark struct {
+	Symbol       string
+	Currency     string
+	Price        money.Amount
+	PriceFetched time.Time
+	ROI          ReturnOnInvestment
+	TotalROI     ReturnOnInvestment
+	FetchError   string
+}
+
+// LoadBenchmarks are the benchmarks of the results fetched for them, with the dividends of dataDir/dividends as of now.
+// The dividends are loaded on their own, a portfolio only keeps the ones of the stocks it bought.
+func LoadBenchmarks(dataDir string, results []provider.Result, now time.Time) ([]Benchmark, error) {
+	p := New()
+	p.Now = now
+	if err := p.LoadDividends(filepath.Join(dataDir, "dividends")); err != nil {
+		return nil, err
+	}
+	return p.Benchmarks(results), nil
+}
+
+// Benchmarks are the benchmarks of the results fetched for them, with the dividends of the stocks of the portfolio
+// with the same symbol.
+func (p *Portfolio) Benchmarks(results []provider.Result) []Benchmark {
+	benchmarks := []Benchmark{}
+	for _, result := range results {
+		benchmark := Benchmark{Symbol: result.Symbol}
+		if result.Err != nil {
+			benchmark.FetchError = result.Err.Error()
+			benchmarks = append(benchmarks, benchmark)
+			continue
+		}
+		currency, perUnit := money.Normalize(result.Quote.Currency)
+		price := result.Quote.Price / float64(perUnit)
+		history := inMainUnit(result.History, perUnit)
+		asOf := quoteDate(result.Quote, p.Now)
+		benchmark.Currency = currency
+		benchmark.Price = money.FromFloat(price)
+		benchmark.PriceFetched = result.Quote.Fetched
+		benchmark.ROI = CalculateROI(price, asOf, history)
+		benchmark.TotalROI = CalculateTotalROI(price, asOf, history, p.Stocks[result.Symbol].Dividends)
+		benchmarks = append(benchmarks, benchmark)
+	}
+	return benchmarks
+}
+
+// PortfolioROI is the time-weighted return of the series over the windows of ReturnOnInvestment, NaN when the series
+// does not go back that far. The windows count back from the last day of the series, like the ROI of a quote.
+func PortfolioROI(series []ValuePoint) ReturnOnInvestment {
+	var asOf time.Time
+	if len(series) > 0 {
+		asOf, _ = time.Parse("2006-01-02", series[len(series)-1].Date)
+	}
+	since := func(t time.Time) float64 {
+		return TWRSince(series, t.Format("2006-01-02"))
+	}
+	var roi ReturnOnInvestment
+	roi.ThreeDays = since(asOf.AddDate(0, 0, -3))
+	roi.OneWeek = since(asOf.AddDate(0, 0, -7))
+	roi.TwoWeeks = since(asOf.AddDate(0, 0, -14))
+	roi.OneMonth = since(asOf.AddDate(0, -1, 0))
+	roi.TwoMonths = since(asOf.AddDate(0, -2, 0))
+	roi.SixMonth = since(asOf.AddDate(0, -6, 0))
+	roi.OneYear = since(asOf.AddDate(-1, 0, 0))
+	roi.TwoYears = since(asOf.AddDate(-2, 0, 0))
+	return roi
+}
+
+// outperformance is by how much the portfolio did better than the benchmark over a window, NaN when either has no
+// return for it. ROISince gives -100 when the history does not go back far enough.
+func outperformance(portfolio float64, benchmark float64) float64 {
+	if math.IsNaN(portfolio) || benchmark == -100 {
+		return math.NaN()
+	}
+	return portfolio - benchmark
+}
+
+// windows are the returns of the ROI windows in their order.
+func (roi ReturnOnInvestment) windows() []float64 {
+	return []float64{roi.ThreeDays, roi.OneWeek, roi.TwoWeeks, roi.OneMonth, roi.TwoMonths, roi.SixMonth, roi.OneYear, roi.TwoYears}
+}
+
+// GetBenchmarkSummaryRow is the benchmark in the columns of GetStockSummaryHeader, n/a for the ones of a position.
+func GetBenchmarkSummaryRow(benchmark Benchmark) string {
+	name := benchmark.Symbol + " (benchmark)"
+	if benchmark.FetchError != "" {
+		return name + ", , n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, unavailable\n"
+	}
+	str := fmt.Sprintf("%s, %s, n/a, n/a, n/a, %s, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a", name, benchmark.Currency, benchmark.Price.In(benchmark.Currency))
+	total := benchmark.TotalROI.windows()
+	for i, price := range benchmark.ROI.windows() {
+		str += fmt.Sprintf(", %.2f%%, %.2f%%", price, total[i])
+	}
+	return str + ", " + formatAge(benchmark.PriceFetched) + "\n"
+}
+
+// GetBenchmarkString compares the time-weighted return of a portfolio with the total return of the benchmarks,
+// a "vs" line is the outperformance of the portfolio over each window.
+func GetBenchmarkString(name string, roi ReturnOnInvestment, benchmarks []Benchmark) string {
+	line := func(label string, returns []float64) string {
+		str := fmt.Sprintf("    %-20s", label)
+		for _, r := range returns {
+			str += fmt.Sprintf(" %9s", formatRate(r))
+		}
+		return str + "\n"
+	}
+	str := fmt.Sprintf("    %-20s %9s %9s %9s %9s %9s %9s %9s %9s\n", "", "3d", "7d", "14d", "1m", "2m", "6m", "1y", "2y")
+	str += line(name, roi.windows())
+	for _, benchmark := range benchmarks {
+		if benchmark.FetchError != "" {
+			str += fmt.Sprintf("    %-20s unavailable - %s\n", benchmark.Symbol, benchmark.FetchError)
+			continue
+		}
+		vs := []float64{}
+		total := benchmark.TotalROI.windows()
+		for i, r := range roi.windows() {
+			vs = append(vs, outperformance(r, total[i]))
+		}
+		str += line(benchmark.Symbol+" TR", total)
+		str += line("  vs "+benchmark.Symbol, vs)
+	}
+	return str
+}
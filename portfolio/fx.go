@@ -0,0 +1,232 @@
+package portfolio
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kmorin72/stock/money"
+	"github.com/kmorin72/stock/provider"
+)
+
+// Converter turns amounts into Currency at the rate of a day. The rates of a currency are the closes of the history
+// of the pair from Source, USDCAD for USD into CAD, or CADUSD inverted when only that one is there. Out of Via, CAD into
+// USD, the pair into Via is tried first and inverted, as the Bank of Canada only has rates into CAD. When neither pair
+// is there, the rate goes through Via, EUR into USD is EURCAD then CADUSD.
+// A day without a rate, a weekend or a holiday, takes the rate of the last day before it that has one.
+type Converter struct {
+	Currency string
+	Source   provider.Provider
+	Via      string
+	rates    map[string]rateSeries
+}
+
+// rateSeries are the days of a pair, most recent first, and if their close is the rate the other way around.
+type rateSeries struct {
+	days   []provider.Day
+	invert bool
+	err    error
+}
+
+func NewConverter(currency string, source provider.Provider) *Converter {
+	return &Converter{currency, source, "CAD", make(map[string]rateSeries)}
+}
+
+// Rate is the amount of Currency one from was worth on date.
+func (c *Converter) Rate(from string, date string) (float64, error) {
+	rate, err := c.pairRate(from, c.Currency, date)
+	if err == nil || c.Via == "" || from == c.Via || c.Currency == c.Via {
+		return rate, err
+	}
+	toVia, viaErr := c.pairRate(from, c.Via, date)
+	if viaErr != nil {
+		return 0, err
+	}
+	fromVia, viaErr := c.pairRate(c.Via, c.Currency, date)
+	if viaErr != nil {
+		return 0, err
+	}
+	return toVia * fromVia, nil
+}
+
+func (c *Converter) pairRate(from string, to string, date string) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	series := c.series(from, to)
+	if series.err != nil {
+		return 0, series.err
+	}
+	for _, day := range series.days {
+		if day.Date <= date && day.Close > 0 {
+			if series.invert {
+				return 1 / day.Close, nil
+			}
+			return day.Close, nil
+		}
+	}
+	return 0, fmt.Errorf("no %s rate on or before %s", provider.FXPair(from, to), date)
+}
+
+func (c *Converter) series(from string, to string) rateSeries {
+	pair := provider.FXPair(from, to)
+	if series, isIn := c.rates[pair]; isIn {
+		return series
+	}
+	direct, inverse := pair, provider.FXPair(to, from)
+	invert := false
+	if from == c.Via {
+		direct, inverse, invert = inverse, direct, true
+	}
+	var series rateSeries
+	if c.Source == nil {
+		series.err = fmt.Errorf("no exchange rates to convert %s into %s", from, to)
+	} else if history, err := c.Source.History(direct); err == nil {
+		series.days = history.Days
+		series.invert = invert
+	} else if history, inverseErr := c.Source.History(inverse); inverseErr == nil {
+		series.days = history.Days
+		series.invert = !invert
+	} else {
+		series.err = err
+	}
+	c.rates[pair] = series
+	return series
+}
+
+// Consolidated are the totals of a portfolio in one currency. The trades and dividends are converted at the rate of
+// their day and the market value at the rate of today, CashFlows are both for the XIRR. Missing has the stocks that could not be converted, with why.
+type Consolidated struct {
+	Currency              string
+	BookValue             money.Amount
+	MarketValue           money.Amount
+	RealizedGains         money.Amount
+	DividendPaid          money.Amount
+	DividendPerYear       map[string]money.Amount
+	DividendLastYear      money.Amount
+	DividendLastSixMonths money.Amount
+	DividendLastMonth     money.Amount
+	CashFlows             []CashFlow
+	Missing               map[string]string
+}
+
+func newConsolidated(currency string) Consolidated {
+	return Consolidated{Currency: currency, DividendPerYear: make(map[string]money.Amount), Missing: make(map[string]string)}
+}
+
+// Consolidate adds up the stocks of the portfolio in the currency of c, as of Now.
+func (p *Portfolio) Consolidate(c *Converter) Consolidated {
+	totals := newConsolidated(c.Currency)
+	today := p.Now.Format("2006-01-02")
+	for _, symbol := range p.Symbols() {
+		stock := p.Stocks[symbol]
+
+		// the rates go back to the first buy once they go back to its day, the dividends before it are not converted
+		first := firstBuy(stock, TimelineDates(stock))
+		if first == "" {
+			continue
+		}
+		if _, err := c.Rate(stock.Currency, first); err != nil {
+			totals.Missing[accountSymbol(p.Account, symbol)] = err.Error()
+			continue
+		}
+		rate := func(date string) (float64, error) {
+			return c.Rate(stock.Currency, date)
+		}
+		tr, err := ProcessTimelineIn(stock, p.Now, c.Currency, rate)
+		if err != nil {
+			totals.Missing[accountSymbol(p.Account, symbol)] = err.Error()
+			continue
+		}
+		flows, err := cashFlowsIn(stock, c.Currency, rate)
+		if err != nil {
+			totals.Missing[accountSymbol(p.Account, symbol)] = err.Error()
+			continue
+		}
+		// a position without a price is left out as a whole, its book value alone would understate the gain
+		if tr.NumberOfShares > 0 && stock.FetchError != "" {
+			totals.Missing[accountSymbol(p.Account, symbol)] = "no price, left out of the totals and the XIRR"
+			continue
+		}
+		mv := money.Zero
+		if tr.NumberOfShares > 0 {
+			todayRate, err := rate(today)
+			if err != nil {
+				totals.Missing[accountSymbol(p.Account, symbol)] = err.Error()
+				continue
+			}
+			mv = stock.Price.MulFloat(tr.NumberOfShares).RoundTo(stock.Currency).MulFloat(todayRate).RoundTo(c.Currency)
+		}
+		totals.BookValue += tr.BookValue
+		totals.RealizedGains += tr.RealizedGains
+		totals.DividendPaid += tr.DividendPaid
+		for year, payout := range tr.DividendPerYear {
+			totals.DividendPerYear[year] += payout
+		}
+		totals.DividendLastYear += tr.DividendLastYear
+		totals.DividendLastSixMonths += tr.DividendLastSixMonths
+		totals.DividendLastMonth += tr.DividendLastMonth
+		if tr.NumberOfShares > 0 {
+			totals.MarketValue += mv
+			totals.CashFlows = append(totals.CashFlows, CashFlow{today, mv})
+		}
+		totals.CashFlows = append(totals.CashFlows, flows...)
+	}
+	return totals
+}
+
+// Add adds the totals of another portfolio in the same currency, the ones of another account.
+func (totals *Consolidated) Add(other Consolidated) {
+	totals.BookValue += other.BookValue
+	totals.MarketValue += other.MarketValue
+	totals.RealizedGains += other.RealizedGains
+	totals.DividendPaid += other.DividendPaid
+	for year, payout := range other.DividendPerYear {
+		totals.DividendPerYear[year] += payout
+	}
+	totals.DividendLastYear += other.DividendLastYear
+	totals.DividendLastSixMonths += other.DividendLastSixMonths
+	totals.DividendLastMonth += other.DividendLastMonth
+	totals.CashFlows = append(totals.CashFlows, other.CashFlows...)
+	for symbol, why := range other.Missing {
+		totals.Missing[symbol] = why
+	}
+}
+
+// Consolidate adds up the Consolidated of every account.
+func (h *Household) Consolidate(c *Converter) Consolidated {
+	totals := newConsolidated(c.Currency)
+	for _, name := range h.Names() {
+		totals.Add(h.Accounts[name].Consolidate(c))
+	}
+	return totals
+}
+
+// GetConsolidatedString has the totals with the stocks left out of them, if any.
+func GetConsolidatedString(totals Consolidated) string {
+	currency := totals.Currency
+	str := "\nConsolidated in " + currency + "\n"
+	line := "    %-24s %s\n"
+	str += fmt.Sprintf(line, "Book Value", totals.BookValue.In(currency))
+	str += fmt.Sprintf(line, "Market Value", totals.MarketValue.In(currency)+"    ["+(totals.MarketValue-totals.BookValue).In(currency)+"]")
+	str += fmt.Sprintf(line, "Realized Gains", totals.RealizedGains.In(currency))
+	str += fmt.Sprintf(line, "XIRR", formatRate(XIRR(totals.CashFlows)))
+	str += fmt.Sprintf(line, "Dividends Last Month", totals.DividendLastMonth.In(currency))
+	str += fmt.Sprintf(line, "Dividends Last 6 Months", totals.DividendLastSixMonths.In(currency))
+	str += fmt.Sprintf(line, "Dividends Last Year", totals.DividendLastYear.In(currency))
+	str += fmt.Sprintf(line, "Dividends total", totals.DividendPaid.In(currency))
+	for _, key := range sortedKeys(totals.DividendPerYear) {
+		str += fmt.Sprintf(line, "Dividends "+key, totals.DividendPerYear[key].In(currency))
+	}
+	if len(totals.Missing) > 0 {
+		symbols := []string{}
+		for symbol := range totals.Missing {
+			symbols = append(symbols, symbol)
+		}
+		sort.Strings(symbols)
+		str += "    Not in the totals:\n"
+		for _, symbol := range symbols {
+			str += fmt.Sprintf("        %-12s : %s\n", symbol, totals.Missing[symbol])
+		}
+	}
+	return str
+}
@@ -0,0 +1,177 @@
+package portfolio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/kmorin72/stock/money"
+	"github.com/kmorin72/stock/provider"
+)
+
+// Household has a Portfolio for each account of a ledger, so the accounts never share a cost base.
+// The transactions without an account are in the "" one.
+type Household struct {
+	Accounts map[string]*Portfolio
+}
+
+// LoadHousehold loads a Portfolio for every account the transactions name, see LoadAccount.
+func LoadHousehold(dataDir string, transactionsFile string) (*Household, error) {
+	rawTransactions, err := ioutil.ReadFile(transactionsFile)
+	if err != nil {
+		return nil, err
+	}
+	var transactionData TransactionsData
+	if err = json.Unmarshal(rawTransactions, &transactionData); err != nil {
+		return nil, fmt.Errorf("%s - %s", transactionsFile, err.Error())
+	}
+
+	h := &Household{make(map[string]*Portfolio)}
+	for _, transaction := range transactionData.Transactions {
+		if _, isIn := h.Accounts[transaction.Account]; isIn {
+			continue
+		}
+		p, err := LoadAccount(dataDir, transactionsFile, transaction.Account)
+		if err != nil {
+			return nil, err
+		}
+		h.Accounts[transaction.Account] = p
+	}
+	if len(h.Accounts) == 0 {
+		h.Accounts[""] = New()
+	}
+	return h, nil
+}
+
+// Names are the accounts in alphabetical order.
+func (h *Household) Names() []string {
+	names := []string{}
+	for name := range h.Accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasAccounts tells if the ledger names its accounts, the reports are then broken down by account.
+func (h *Household) HasAccounts() bool {
+	_, isIn := h.Accounts[""]
+	return len(h.Accounts) > 1 || !isIn
+}
+
+// Symbols are the symbols of every account, in alphabetical order.
+func (h *Household) Symbols() []string {
+	seen := make(map[string]bool)
+	symbols := []string{}
+	for _, p := range h.Accounts {
+		for symbol := range p.Stocks {
+			if !seen[symbol] {
+				seen[symbol] = true
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// Keep drops the stocks that are not in symbols from every account.
+func (h *Household) Keep(symbols []string) {
+	for _, p := range h.Accounts {
+		p.Keep(symbols)
+	}
+}
+
+// SetLotMethod changes the lot method of every account and computes their lots again.
+func (h *Household) SetLotMethod(method string) {
+	for _, p := range h.Accounts {
+		p.LotMethod = method
+		p.Process()
+	}
+}
+
+// SetWashSales applies the US wash sale rule to the accounts washSales is true for and computes their lots again.
+func (h *Household) SetWashSales(washSales func(account string) bool) {
+	for name, p := range h.Accounts {
+		if washSales(name) {
+			p.WashSales = true
+			p.Process()
+		}
+	}
+}
+
+// FetchMarketData gets the market data of every symbol once and gives it to all the accounts that hold it.
+func (h *Household) FetchMarketData(source provider.Provider, workers int) {
+	results := provider.FetchAll(source, h.Symbols(), workers)
+	for _, p := range h.Accounts {
+		p.SetMarketData(results)
+	}
+}
+
+// Total is a Portfolio with the positions of all the accounts added up by symbol, for the household report.
+// Its stocks have the trades, lots, cash flows and TimeLineResult of every account and the market data, not a timeline to process.
+func (h *Household) Total() *Portfolio {
+	total := New()
+	total.Account = "household"
+	for _, name := range h.Names() {
+		p := h.Accounts[name]
+		total.Now = p.Now
+		total.LotMethod = p.LotMethod
+		for path, err := range p.Errors {
+			total.Errors[path] = err
+		}
+		for _, symbol := range p.Symbols() {
+			stock := p.Stocks[symbol]
+			held, isIn := total.Stocks[symbol]
+			if !isIn {
+				// a copy the other accounts can be added to
+				held = stock
+				held.Timeline = nil
+				held.Buys = append([]Tx{}, stock.Buys...)
+				held.Sells = append([]Tx{}, stock.Sells...)
+				held.CashFlows = append([]CashFlow{}, stock.CashFlows...)
+				held.Lots = LotResult{stock.Lots.Method, append([]Lot{}, stock.Lots.Lots...), append([]LotSale{}, stock.Lots.Sales...)}
+				held.TLR = addTimeLineResult(TimeLineResult{DividendPerYear: make(map[string]money.Amount), FeesPerYear: make(map[string]money.Amount)}, stock.TLR)
+				held.TLR.AveragePrice = stock.TLR.AveragePrice
+				total.Stocks[symbol] = held
+				continue
+			}
+			held.Buys = append(held.Buys, stock.Buys...)
+			held.Sells = append(held.Sells, stock.Sells...)
+			held.CashFlows = append(held.CashFlows, stock.CashFlows...)
+			held.XIRR = stockXIRR(held, total.Now)
+			held.Lots.Lots = append(held.Lots.Lots, stock.Lots.Lots...)
+			held.Lots.Sales = append(held.Lots.Sales, stock.Lots.Sales...)
+			held.TLR = addTimeLineResult(held.TLR, stock.TLR)
+			total.Stocks[symbol] = held
+		}
+	}
+	return total
+}
+
+// addTimeLineResult adds up the positions and dividends of the same stock in two accounts.
+func addTimeLineResult(a TimeLineResult, b TimeLineResult) TimeLineResult {
+	a.NumberOfShares = roundShares(a.NumberOfShares + b.NumberOfShares)
+	a.BookValue += b.BookValue
+	a.AveragePrice = money.Zero
+	if a.NumberOfShares > 0 {
+		a.AveragePrice = a.BookValue.DivFloat(a.NumberOfShares)
+	}
+	a.DividendPaid += b.DividendPaid
+	for year, payout := range b.DividendPerYear {
+		a.DividendPerYear[year] += payout
+	}
+	if b.DividendHikes > a.DividendHikes {
+		a.DividendHikes = b.DividendHikes
+	}
+	a.DividendLastYear += b.DividendLastYear
+	a.DividendLastSixMonths += b.DividendLastSixMonths
+	a.DividendLastMonth += b.DividendLastMonth
+	a.RealizedGains += b.RealizedGains
+	a.Fees += b.Fees
+	for year, fees := range b.FeesPerYear {
+		a.FeesPerYear[year] += fees
+	}
+	return a
+}
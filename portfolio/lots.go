@@ -0,0 +1,202 @@
+package portfolio
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kmorin72/stock/money"
+)
+
+// the orders the sells take the lots in, when the sell does not name its lots
+const (
+	FIFO        = "fifo"
+	LIFO        = "lifo"
+	HighestCost = "hifo"
+)
+
+// LotMethods are the lot methods ComputeLots knows, FIFO when none is given.
+var LotMethods = []string{FIFO, LIFO, HighestCost}
+
+// ValidLotMethod tells if method is one of LotMethods or empty.
+func ValidLotMethod(method string) bool {
+	if method == "" {
+		return true
+	}
+	for _, m := range LotMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Lot is the shares of one buy. Remaining is what the sells have not taken yet and Basis is their cost with the commission and fees.
+// The holding period starts on Acquired, the date of the buy unless the lot replaced shares sold in a wash sale.
+// WashSale is the loss added to the basis then, a lot is split when only part of it replaced shares.
+type Lot struct {
+	ID        string
+	Date      string
+	Acquired  string
+	Quantity  float64
+	Remaining float64
+	Basis     money.Amount
+	WashSale  money.Amount
+	// replacement is set once the lot replaced shares, it can't replace others
+	replacement bool
+}
+
+// LotSale is the part of a sell that one lot gave, the commission and fees of the sell are shared by the shares.
+// Disallowed is the loss of a wash sale, it is taken off the loss in Gain.
+type LotSale struct {
+	SellID     string
+	LotID      string
+	Date       string
+	Acquired   string
+	Quantity   float64
+	Proceeds   money.Amount
+	Basis      money.Amount
+	Disallowed money.Amount
+	Gain       money.Amount
+	LongTerm   bool
+}
+
+// LotResult are the lots of a stock after its whole ledger, the closed ones included, and the sales that took from them.
+type LotResult struct {
+	Method string
+	Lots   []Lot
+	Sales  []LotSale
+}
+
+// HoldingDays is how long the lot has been held on date.
+func (lot Lot) HoldingDays(date time.Time) int {
+	acquired, err := time.Parse("2006-01-02", lot.Acquired)
+	if err != nil {
+		return 0
+	}
+	return int(date.Sub(acquired).Hours() / 24)
+}
+
+// longTerm tells if shares acquired on one date and sold on another were held more than a year.
+func longTerm(acquired string, sold string) bool {
+	date, err := time.Parse("2006-01-02", acquired)
+	if err != nil {
+		return false
+	}
+	return sold > date.AddDate(1, 0, 0).Format("2006-01-02")
+}
+
+// ComputeLots makes a lot of every buy and takes the shares of each sell from the lots it names first, then from
+// the open lots in the order of method. The wash sales are applied when washSaleRule is set, see washSales.
+func ComputeLots(stock Stock, method string, washSaleRule bool) LotResult {
+	if method == "" {
+		method = FIFO
+	}
+	result := LotResult{Method: method}
+	txs := txByID(stock)
+	wash := washSales{}
+	for _, date := range TimelineDates(stock) {
+		for _, event := range stock.Timeline[date] {
+			tx, isTx := txs[event.TxID]
+			if !isTx {
+				continue
+			}
+			switch event.Type {
+			case "buy":
+				basis := tx.Price.MulFloat(tx.Quantity).RoundTo(stock.Currency) + tx.Costs().RoundTo(stock.Currency)
+				result.Lots = append(result.Lots, Lot{ID: tx.ID, Date: date, Acquired: date, Quantity: tx.Quantity, Remaining: tx.Quantity, Basis: basis})
+				if washSaleRule {
+					wash.bought(stock, &result, len(result.Lots)-1)
+				}
+			case "sell":
+				first := len(result.Sales)
+				result.Sales = append(result.Sales, sellLots(stock, result.Lots, lotOrder(result.Lots, tx.Lots, method), tx)...)
+				if washSaleRule {
+					for i := first; i < len(result.Sales); i++ {
+						wash.sold(stock, &result, i)
+					}
+				}
+			}
+		}
+	}
+	return result
+}
+
+// lotOrder are the indexes of the open lots, the named ones first in their order, then the others by method.
+func lotOrder(lots []Lot, named []string, method string) []int {
+	order := []int{}
+	taken := make(map[int]bool)
+	for _, id := range named {
+		for i, lot := range lots {
+			if lot.ID == id && lot.Remaining > 0 && !taken[i] {
+				order = append(order, i)
+				taken[i] = true
+			}
+		}
+	}
+	rest := []int{}
+	for i, lot := range lots {
+		if lot.Remaining > 0 && !taken[i] {
+			rest = append(rest, i)
+		}
+	}
+	switch method {
+	case LIFO:
+		sort.SliceStable(rest, func(a, b int) bool { return rest[a] > rest[b] })
+	case HighestCost:
+		sort.SliceStable(rest, func(a, b int) bool {
+			return lots[rest[a]].Basis.DivFloat(lots[rest[a]].Remaining) > lots[rest[b]].Basis.DivFloat(lots[rest[b]].Remaining)
+		})
+	}
+	return append(order, rest...)
+}
+
+// sellLots takes the shares of sell from lots in order, the shares sold past the open lots are not matched to any.
+func sellLots(stock Stock, lots []Lot, order []int, sell Tx) []LotSale {
+	sales := []LotSale{}
+	left := sell.Quantity
+	for _, i := range order {
+		if left <= 0 {
+			break
+		}
+		lot := &lots[i]
+		quantity := minShares(left, lot.Remaining)
+		basis := lot.Basis
+		if quantity < lot.Remaining {
+			basis = lot.Basis.MulFloat(quantity).DivFloat(lot.Remaining).RoundTo(stock.Currency)
+		}
+		proceeds := sell.Price.MulFloat(quantity) - sell.Costs().MulFloat(quantity).DivFloat(sell.Quantity)
+		proceeds = proceeds.RoundTo(stock.Currency)
+		sales = append(sales, LotSale{sell.ID, lot.ID, sell.Date, lot.Acquired, quantity, proceeds, basis, money.Zero, proceeds - basis, longTerm(lot.Acquired, sell.Date)})
+		lot.Basis -= basis
+		lot.Remaining = roundShares(lot.Remaining - quantity)
+		left = roundShares(left - quantity)
+	}
+	return sales
+}
+
+// GetLotsString lists the open lots of the stock with how long they have been held on now, then the sales by lot.
+func GetLotsString(stock Stock, now time.Time) string {
+	currency := stock.Currency
+	str := "Lots of " + stock.Symbol + " (" + stock.Lots.Method + ")\n"
+	for _, lot := range stock.Lots.Lots {
+		if lot.Remaining <= 0 {
+			continue
+		}
+		str += fmt.Sprintf("    %-24s %s  %9s of %9s  cost %12s  held %5d days\n", lot.ID, lot.Acquired, FormatShares(lot.Remaining),
+			FormatShares(lot.Quantity), lot.Basis.In(currency), lot.HoldingDays(now))
+	}
+	for _, sale := range stock.Lots.Sales {
+		term := "short"
+		if sale.LongTerm {
+			term = "long"
+		}
+		str += fmt.Sprintf("    sold %s  %9s from %-24s proceeds %12s  cost %12s  gain %12s  %s term", sale.Date, FormatShares(sale.Quantity),
+			sale.LotID, sale.Proceeds.In(currency), sale.Basis.In(currency), sale.Gain.In(currency), term)
+		if !sale.Disallowed.IsZero() {
+			str += fmt.Sprintf("  wash sale %s", sale.Disallowed.In(currency))
+		}
+		str += "\n"
+	}
+	return str
+}
@@ -0,0 +1,304 @@
+package portfolio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kmorin72/stock/money"
+	"github.com/kmorin72/stock/provider"
+)
+
+// Portfolio holds the stocks of a ledger with their splits, dividends and market data.
+// Everything it computes is returned or kept on the Portfolio, so several can be used side by side.
+type Portfolio struct {
+	Stocks map[string]Stock
+	// Errors has the data files and symbols that could not be used, with why
+	Errors map[string]string
+	// Now is the day the dividend windows are counted back from
+	Now time.Time
+	// LotMethod is the order the sells take the lots in, one of LotMethods
+	LotMethod string
+	// Account is the account of the ledger the portfolio has the transactions of, "" for the ones without
+	Account string
+	// WashSales applies the US wash sale rule to the lots, for an account filed with US taxes
+	WashSales bool
+}
+
+func New() *Portfolio {
+	return &Portfolio{make(map[string]Stock), make(map[string]string), time.Now(), FIFO, "", false}
+}
+
+// Load reads dataDir/splits.json, the dividend files of dataDir/dividends and the transactions without an account,
+// keeps the stocks that were bought and processes their timeline. See LoadHousehold for a ledger with accounts.
+func Load(dataDir string, transactionsFile string) (*Portfolio, error) {
+	return LoadAccount(dataDir, transactionsFile, "")
+}
+
+// LoadAccount is Load for the transactions of account.
+func LoadAccount(dataDir string, transactionsFile string, account string) (*Portfolio, error) {
+	p := New()
+	p.Account = account
+	if err := p.LoadSplits(filepath.Join(dataDir, "splits.json")); err != nil {
+		return nil, err
+	}
+	if err := p.LoadDividends(filepath.Join(dataDir, "dividends")); err != nil {
+		return nil, err
+	}
+	if err := p.LoadTransactions(transactionsFile); err != nil {
+		return nil, err
+	}
+
+	// only the stocks we bought are kept
+	for symbol, stock := range p.Stocks {
+		if len(stock.Buys) == 0 {
+			delete(p.Stocks, symbol)
+		}
+	}
+	p.Process()
+	return p, nil
+}
+
+// Stock returns the stock of the symbol, creating it if it does not exist.
+func (p *Portfolio) Stock(symbol string) Stock {
+	if _, isIn := p.Stocks[symbol]; !isIn {
+		p.Stocks[symbol] = newStock(symbol)
+	}
+	return p.Stocks[symbol]
+}
+
+// Symbols are the symbols of the portfolio in alphabetical order.
+func (p *Portfolio) Symbols() []string {
+	symbols := []string{}
+	for symbol := range p.Stocks {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// Keep drops the stocks that are not in symbols, nothing when symbols is empty.
+func (p *Portfolio) Keep(symbols []string) {
+	if len(symbols) == 0 {
+		return
+	}
+	keep := make(map[string]bool)
+	for _, symbol := range symbols {
+		keep[symbol] = true
+	}
+	for symbol := range p.Stocks {
+		if !keep[symbol] {
+			delete(p.Stocks, symbol)
+		}
+	}
+}
+
+func (p *Portfolio) LoadSplits(file string) error {
+	rawSplits, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var splitData SplitData
+	if err = json.Unmarshal(rawSplits, &splitData); err != nil {
+		return fmt.Errorf("%s - %s", file, err.Error())
+	}
+	for _, split := range splitData.Splits {
+		stock := p.Stock(split.Symbol)
+		stock.Splits[split.Date] = split
+		addStockEvent(stock, split.Date, StockEvent{"split", 0, 0, split.To, split.From, "", money.Zero})
+	}
+	return nil
+}
+
+// LoadDividends reads every dividend file under dir, a file that can't be read is put in Errors and skipped.
+func (p *Portfolio) LoadDividends(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rawDividends, err := ioutil.ReadFile(path)
+		if err != nil {
+			p.Errors[path] = err.Error()
+			return nil
+		}
+		var dividendData DividendData
+		if err = json.Unmarshal(rawDividends, &dividendData); err != nil {
+			p.Errors[path] = err.Error()
+			return nil
+		}
+		for _, dividend := range dividendData.Dividends {
+
+			if strings.Index(dividend.Date, "-") == -1 {
+				t, _ := time.Parse("01/02/06", dividend.Date)
+				dividend.Date = t.Format("2006-01-02")
+			}
+
+			stock := p.Stock(dividendData.Symbol)
+			stock.Dividends[dividend.Date] = dividend
+			addStockEvent(stock, dividend.Date, StockEvent{"dividend", 0, dividend.Amount, 0, 0, "", money.Zero})
+		}
+		return nil
+	})
+}
+
+// LoadTransactions reads the buys and sells of the Account, adjusted for the splits loaded before.
+func (p *Portfolio) LoadTransactions(file string) error {
+	rawTransactions, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var transactionData TransactionsData
+	if err = json.Unmarshal(rawTransactions, &transactionData); err != nil {
+		return fmt.Errorf("%s - %s", file, err.Error())
+	}
+	perDay := make(map[string]int)
+	for _, transaction := range transactionData.Transactions {
+		// every transaction is kept, the ones without an id are numbered in their order of the day
+		day := transaction.Symbol + ":" + transaction.Date
+		perDay[day]++
+		id := transaction.ID
+		if id == "" {
+			id = fmt.Sprintf("%s#%d", day, perDay[day])
+		}
+		if transaction.Account != p.Account {
+			continue
+		}
+
+		// the transactions before a split are restated in post split shares
+		stock := p.Stock(transaction.Symbol)
+		dates := []string{}
+		for k := range stock.Splits {
+			dates = append(dates, k)
+		}
+		sort.Strings(dates)
+		for _, date := range dates {
+			if transaction.Date < date {
+				split := stock.Splits[date]
+				transaction.Quantity = roundShares(transaction.Quantity * float64(split.To) / float64(split.From))
+				transaction.Price = transaction.Price.Mul(money.FromInt(int64(split.From))).Div(money.FromInt(int64(split.To)))
+			}
+		}
+
+		tx := Tx{id, transaction.Date, transaction.Quantity, transaction.Price, transaction.Commission, transaction.Fees, transaction.Lots}
+		if strings.Compare(transaction.Type, "buy") == 0 {
+			stock.Buys = append(stock.Buys, tx)
+			addStockEvent(stock, transaction.Date, StockEvent{"buy", transaction.Quantity, transaction.Price, 0, 0, id, tx.Costs()})
+		} else {
+			stock.Sells = append(stock.Sells, tx)
+			addStockEvent(stock, transaction.Date, StockEvent{"sell", transaction.Quantity, transaction.Price, 0, 0, id, tx.Costs()})
+		}
+		p.Stocks[transaction.Symbol] = stock
+	}
+	return nil
+}
+
+// Process computes the TimeLineResult and the lots of every stock again, as of Now.
+func (p *Portfolio) Process() {
+	for symbol, stock := range p.Stocks {
+		stock.TLR = ProcessTimeline(stock, p.Now)
+		stock.Lots = ComputeLots(stock, p.LotMethod, p.WashSales)
+		stock.CashFlows = CashFlows(stock)
+		stock.XIRR = stockXIRR(stock, p.Now)
+		p.Stocks[symbol] = stock
+	}
+}
+
+// FetchMarketData gets the quote and history of every stock from source, with workers at the same time, and
+// computes the price and total ROI from them. A stock the source fails on keeps its FetchError and no market data.
+func (p *Portfolio) FetchMarketData(source provider.Provider, workers int) {
+	p.SetMarketData(provider.FetchAll(source, p.Symbols(), workers))
+}
+
+// SetMarketData is FetchMarketData with the results already fetched, the ones of other symbols are skipped.
+func (p *Portfolio) SetMarketData(results []provider.Result) {
+	for _, result := range results {
+		stock, isIn := p.Stocks[result.Symbol]
+		if !isIn {
+			continue
+		}
+		if result.Err != nil {
+			// the quote still tells the currency when only the history failed, else it stays the one of the exchange
+			stock.FetchError = result.Err.Error()
+			if result.Quote.Currency != "" {
+				stock.Currency, _ = money.Normalize(result.Quote.Currency)
+				stock.TLR = ProcessTimeline(stock, p.Now)
+				stock.Lots = ComputeLots(stock, p.LotMethod, p.WashSales)
+				stock.CashFlows = CashFlows(stock)
+			}
+			p.Stocks[result.Symbol] = stock
+			continue
+		}
+		// a quote in pence is in pounds for the ledger, and so is its history
+		currency, perUnit := money.Normalize(result.Quote.Currency)
+		stock.FetchError = ""
+		stock.Name = result.Quote.Name
+		stock.Currency = currency
+		stock.Price = money.FromFloat(result.Quote.Price).Div(money.FromInt(perUnit))
+		stock.FiftyTwoWeekHigh = money.FromFloat(result.Quote.FiftyTwoWeekHigh).Div(money.FromInt(perUnit))
+		stock.PriceFetched = result.Quote.Fetched
+		stock.HistoricalData = inMainUnit(result.History, perUnit)
+		stock.ROI = CalculateROI(result.Quote.Price/float64(perUnit), quoteDate(result.Quote, p.Now), stock.HistoricalData)
+		stock.TotalROI = CalculateTotalROI(result.Quote.Price/float64(perUnit), quoteDate(result.Quote, p.Now), stock.HistoricalData, stock.Dividends)
+		// the amounts are rounded by the currency, which we only know now
+		stock.TLR = ProcessTimeline(stock, p.Now)
+		stock.Lots = ComputeLots(stock, p.LotMethod, p.WashSales)
+		stock.CashFlows = CashFlows(stock)
+		stock.XIRR = stockXIRR(stock, p.Now)
+		p.Stocks[result.Symbol] = stock
+	}
+}
+
+// inMainUnit is the history with its prices divided by perUnit, the days are copied as the cache shares them.
+func inMainUnit(history provider.History, perUnit int64) provider.History {
+	if perUnit == 1 {
+		return history
+	}
+	days := make([]provider.Day, len(history.Days))
+	for i, day := range history.Days {
+		unit := float64(perUnit)
+		day.Open, day.High, day.Low, day.Close = day.Open/unit, day.High/unit, day.Low/unit, day.Close/unit
+		days[i] = day
+	}
+	history.Days = days
+	return history
+}
+
+// DividendTotals are the dividends received over the whole portfolio, by currency.
+type DividendTotals struct {
+	DividendPerYear map[string]map[string]money.Amount
+	Dividend1Month  map[string]money.Amount
+	Dividend6Months map[string]money.Amount
+	Dividend1Year   map[string]money.Amount
+}
+
+// Currencies are the currencies of the totals in alphabetical order.
+func (totals DividendTotals) Currencies() []string {
+	return sortedKeys(totals.Dividend1Year)
+}
+
+// DividendTotals adds up the TimeLineResult of the stocks, each in its currency.
+func (p *Portfolio) DividendTotals() DividendTotals {
+	totals := DividendTotals{make(map[string]map[string]money.Amount), make(map[string]money.Amount), make(map[string]money.Amount), make(map[string]money.Amount)}
+	for _, stock := range p.Stocks {
+		tr := stock.TLR
+		if _, isIn := totals.DividendPerYear[stock.Currency]; !isIn {
+			totals.DividendPerYear[stock.Currency] = make(map[string]money.Amount)
+		}
+		for year, payout := range tr.DividendPerYear {
+			totals.DividendPerYear[stock.Currency][year] += payout
+		}
+		totals.Dividend1Month[stock.Currency] += tr.DividendLastMonth
+		totals.Dividend6Months[stock.Currency] += tr.DividendLastSixMonths
+		totals.Dividend1Year[stock.Currency] += tr.DividendLastYear
+	}
+	return totals
+}
@@ -0,0 +1,250 @@
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kmorin72/stock/money"
+	"github.com/kmorin72/stock/provider"
+)
+
+func GetStockSummaryHeader() string {
+	return "Symbol, Currency, Shares, AvgPrice, BookValue, Price, MarketValue, Divy, 1 year, Hikes, Gain, Gain%, XIRR, 52WHigh, (% from high), 3d, 3d TR, 7d, 7d TR, 14d, 14d TR, 1m, 1m TR, 2m, 2m TR, 6m, 6m TR, 1y, 1y TR, 2y, 2y TR, Price Age\n"
+}
+
+// GetStockSummaryRow has the price return of every window of the ROI next to its total return (TR), with the dividends.
+func GetStockSummaryRow(stock Stock) string {
+	tr := stock.TLR
+	roi, total := stock.ROI, stock.TotalROI
+	currency := stock.Currency
+
+	bv := tr.BookValue
+	if stock.FetchError != "" {
+		return fmt.Sprintf(stock.Symbol+", "+currency+", %s, %s, %s, n/a, n/a, %s, %s, %d, n/a, n/a, %s, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, n/a, unavailable\n",
+			FormatShares(tr.NumberOfShares), tr.AveragePrice.In(currency), bv.In(currency), tr.DividendPaid.In(currency), tr.DividendLastYear.In(currency), tr.DividendHikes, formatRate(stock.XIRR))
+	}
+	mv := stock.Price.MulFloat(tr.NumberOfShares).RoundTo(currency)
+	gp := percentChange(tr.AveragePrice, stock.Price)
+	fiftytwop := percentChange(stock.FiftyTwoWeekHigh, stock.Price)
+	str := fmt.Sprintf(stock.Symbol+", "+currency+", %s, %s, %s, %s, %s, %s, %s, %d, %s, %.2f%%, %s, %s, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %s\n",
+		FormatShares(tr.NumberOfShares), tr.AveragePrice.In(currency), bv.In(currency), stock.Price.In(currency), mv.In(currency), tr.DividendPaid.In(currency), tr.DividendLastYear.In(currency), tr.DividendHikes, (mv - bv).In(currency), gp, formatRate(stock.XIRR), stock.FiftyTwoWeekHigh.In(currency), fiftytwop, roi.ThreeDays, total.ThreeDays, roi.OneWeek, total.OneWeek, roi.TwoWeeks, total.TwoWeeks, roi.OneMonth, total.OneMonth, roi.TwoMonths, total.TwoMonths, roi.SixMonth, total.SixMonth, roi.OneYear, total.OneYear, roi.TwoYears, total.TwoYears, formatAge(stock.PriceFetched))
+	return str
+}
+
+// GetStockDetailsString has the details of the stock, the open lots with how long they have been held on now.
+func GetStockDetailsString(stock Stock, now time.Time) string {
+	currency := stock.Currency
+	str := fmt.Sprintf("Symbol          : %9s    ("+currency+")\n", stock.Symbol)
+	if stock.FetchError != "" {
+		str += "Current Price   :       n/a    [" + stock.FetchError + "]\n"
+	}
+	if stock.TLR.NumberOfShares > 0 {
+		str += fmt.Sprintf("Shares          : %9s\n", FormatShares(stock.TLR.NumberOfShares))
+		str += fmt.Sprintf("Average Price   : %9s    [%9s]\n", stock.TLR.AveragePrice.In(currency), stock.TLR.BookValue.In(currency))
+	}
+	if stock.FetchError != "" {
+		// nothing to compare to without the price
+	} else if stock.TLR.NumberOfShares > 0 {
+		pnl := percentChange(stock.TLR.AveragePrice, stock.Price)
+		mv := stock.Price.MulFloat(stock.TLR.NumberOfShares).RoundTo(currency)
+		str += fmt.Sprintf("Current Price   : %9s    [%8.2f%%]\n", stock.Price.In(currency), pnl)
+		str += fmt.Sprintf("Market Value    : %9s    [%9s]\n", mv.In(currency), (mv - stock.TLR.BookValue).In(currency))
+	} else {
+		str += fmt.Sprintf("Current Price   : %9s\n", stock.Price.In(currency))
+		// get the average sale price and the last sale price
+		lastSale := ""
+		lastSaleAmount := money.Zero
+		totalQuantity := 0.0
+		totalSale := money.Zero
+		for _, tx := range stock.Sells {
+			totalSale += tx.Price.MulFloat(tx.Quantity)
+			totalQuantity += tx.Quantity
+			if tx.Date >= lastSale {
+				lastSale = tx.Date
+				lastSaleAmount = tx.Price
+			}
+		}
+		avg := totalSale.DivFloat(totalQuantity)
+		avgpnl := percentChange(avg, stock.Price)
+		str += fmt.Sprintf("Avg Sale Price  : %9s    [perf vs sale = %8.2f%% ]\n", avg.In(currency), avgpnl)
+		lspnl := percentChange(lastSaleAmount, stock.Price)
+		str += fmt.Sprintf("Last Sale Price : %9s    [perf vs sale = %8.2f%% ]\n", lastSaleAmount.In(currency), lspnl)
+	}
+	if !stock.PriceFetched.IsZero() {
+		str += fmt.Sprintf("Price Fetched   : %s    [%s old]\n", stock.PriceFetched.Format("2006-01-02 15:04"), formatAge(stock.PriceFetched))
+	}
+	if len(stock.Sells) > 0 {
+		str += fmt.Sprintf("Realized Gains  : %9s\n", stock.TLR.RealizedGains.In(currency))
+	}
+	if !math.IsNaN(stock.XIRR) {
+		str += fmt.Sprintf("XIRR            : %8.2f%%\n", stock.XIRR)
+	}
+	if !stock.TLR.Fees.IsZero() {
+		str += fmt.Sprintf("Fees total      : %9s\n", stock.TLR.Fees.In(currency))
+		for _, key := range sortedKeys(stock.TLR.FeesPerYear) {
+			str += fmt.Sprintf("Fees "+key+"       : %9s\n", stock.TLR.FeesPerYear[key].In(currency))
+		}
+	}
+	if stock.TLR.NumberOfShares > 0 && len(stock.Lots.Lots) > 1 {
+		for _, lot := range stock.Lots.Lots {
+			if lot.Remaining > 0 {
+				str += fmt.Sprintf("Lot %-12s: %9s    [%9s]    %d days\n", lot.ID, FormatShares(lot.Remaining), lot.Basis.In(currency), lot.HoldingDays(now))
+			}
+		}
+	}
+	str += fmt.Sprintf("Dividends total : %9s\n", stock.TLR.DividendPaid.In(currency))
+	for _, key := range sortedKeys(stock.TLR.DividendPerYear) {
+		value := stock.TLR.DividendPerYear[key]
+		str += fmt.Sprintf("Dividends "+key+"  : %9s\n", value.In(currency))
+	}
+	if stock.TLR.DividendPaid > 0 {
+		str += fmt.Sprintf("Dividend Hikes  : %9d\n", stock.TLR.DividendHikes)
+	}
+	str += "\n=====\n=====\n\n"
+	return str
+}
+
+// GetDividendSummaryString has a section for each currency the portfolio holds.
+func GetDividendSummaryString(totals DividendTotals) string {
+	str := ""
+	for i, currency := range totals.Currencies() {
+		if i > 0 {
+			str += "\n"
+		}
+		str += fmt.Sprintf("%s Dividends Last Month     %s\n", currency, totals.Dividend1Month[currency].In(currency))
+		str += fmt.Sprintf("%s Dividends Last 6 Months  %s\n", currency, totals.Dividend6Months[currency].In(currency))
+		str += fmt.Sprintf("%s Dividends Last Year      %s\n", currency, totals.Dividend1Year[currency].In(currency))
+
+		str += "\n" + currency + " Dividend per year\n"
+		for _, key := range sortedKeys(totals.DividendPerYear[currency]) {
+			str += fmt.Sprintf("    "+key+"  : %s\n", totals.DividendPerYear[currency][key].In(currency))
+		}
+	}
+	return str
+}
+
+// GetFeesString has the commissions and fees paid per year and per symbol, by currency. Empty when none were paid.
+func (p *Portfolio) GetFeesString() string {
+	perYear := make(map[string]map[string]money.Amount)
+	perSymbol := make(map[string]map[string]money.Amount)
+	for _, symbol := range p.Symbols() {
+		stock := p.Stocks[symbol]
+		if stock.TLR.Fees.IsZero() {
+			continue
+		}
+		if _, isIn := perYear[stock.Currency]; !isIn {
+			perYear[stock.Currency] = make(map[string]money.Amount)
+			perSymbol[stock.Currency] = make(map[string]money.Amount)
+		}
+		for year, fees := range stock.TLR.FeesPerYear {
+			perYear[stock.Currency][year] += fees
+		}
+		perSymbol[stock.Currency][symbol] = stock.TLR.Fees
+	}
+
+	currencies := []string{}
+	for currency := range perYear {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	str := ""
+	for _, currency := range currencies {
+		str += "\n" + currency + " Fees per year\n"
+		for _, key := range sortedKeys(perYear[currency]) {
+			str += fmt.Sprintf("    "+key+"  : %s\n", perYear[currency][key].In(currency))
+		}
+		str += "\n" + currency + " Fees per symbol\n"
+		for _, key := range sortedKeys(perSymbol[currency]) {
+			str += fmt.Sprintf("    %-10s : %s\n", key, perSymbol[currency][key].In(currency))
+		}
+	}
+	return str
+}
+
+// GetErrorsString lists the symbols and data files the report could not use, empty when there are none
+func (p *Portfolio) GetErrorsString() string {
+	errors := make(map[string]string)
+	for symbol, stock := range p.Stocks {
+		if stock.FetchError != "" {
+			errors[symbol] = stock.FetchError
+		}
+	}
+	for path, err := range p.Errors {
+		errors[path] = err
+	}
+	if len(errors) == 0 {
+		return ""
+	}
+
+	keys := []string{}
+	for k := range errors {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	str := "Unavailable data (prices or dividends missing from this report)\n"
+	for _, key := range keys {
+		str += fmt.Sprintf("    %-12s : %s\n", key, errors[key])
+	}
+	str += "\n=====\n=====\n\n"
+	return str
+}
+
+func GetTimelineString(stock Stock) string {
+	str := "Stock : " + stock.Symbol + "\n"
+	for _, key := range TimelineDates(stock) {
+		str += "    " + key + "\n"
+		for _, event := range stock.Timeline[key] {
+			str += fmt.Sprintf("         "+event.Type+" %s @ %s split %d:%d", FormatShares(event.Quantity), event.Amount, event.SplitTo, event.SplitFrom)
+			if event.TxID != "" {
+				str += "    [" + event.TxID + "]"
+			}
+			str += "\n"
+		}
+	}
+	return str
+}
+
+// GetScreenString gives the ROI of every symbol that source has data for, one line per symbol.
+func GetScreenString(source provider.Provider, symbols []string, workers int, now time.Time) string {
+	str := ""
+	for _, result := range provider.FetchAll(source, symbols, workers) {
+		if result.Err != nil {
+			str += result.Symbol + "\t\tunavailable - " + result.Err.Error() + "\n"
+			continue
+		}
+		roi := CalculateROI(result.Quote.Price, quoteDate(result.Quote, now), result.History)
+		str += fmt.Sprintf(result.Symbol+"\t\t3 days:  %.2f%%\t\t1 week : %.2f%%\t\t2 weeks : %.2f%%\t\t1 month: %.2f%%\t\t2 months: %.2f%%\t\t6 months: %.2f%%\t\t1 year: %.2f%%\t\t2 years: %.2f%%\n", roi.ThreeDays, roi.OneWeek, roi.TwoWeeks, roi.OneMonth, roi.TwoMonths, roi.SixMonth, roi.OneYear, roi.TwoYears)
+	}
+	return str
+}
+
+// formatAge gives how long ago t was in its largest unit, e.g. 45m, 3h or 2d
+func formatAge(t time.Time) string {
+	if t.IsZero() {
+		return "?"
+	}
+	age := time.Since(t)
+	switch {
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(age.Hours()/24))
+}
+
+// percentChange is the change going from from to to, in %
+func percentChange(from money.Amount, to money.Amount) float64 {
+	return (to.Float64()/from.Float64() - 1) * 100
+}
+
+func sortedKeys(m map[string]money.Amount) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,68 @@
+package portfolio
+
+import (
+	"time"
+
+	"github.com/kmorin72/stock/provider"
+)
+
+// ROISince is the % change from the close on target, or the last close before it, to price. -100 when the history does not go back that far.
+func ROISince(price float64, target time.Time, history provider.History) float64 {
+	return TotalROISince(price, target, history, nil)
+}
+
+// TotalROISince is ROISince with the dividends paid after the close it starts from added to price, the total return
+// of holding a share and keeping its dividends in cash.
+func TotalROISince(price float64, target time.Time, history provider.History, dividends map[string]Dividend) float64 {
+
+	// iterate the history until you hit the date or something before to get the ROI
+	for _, day := range history.Days {
+		t, _ := time.Parse("2006-01-02", day.Date)
+
+		// if the target is not after, it is the same or before
+		if !target.Before(t) {
+			for date, dividend := range dividends {
+				if date > day.Date {
+					price += dividend.Amount.Float64()
+				}
+			}
+			return (price/day.Close - 1) * 100
+		}
+	}
+	return -100
+}
+
+// CalculateROI fills every window of ReturnOnInvestment, counting back from asOf.
+func CalculateROI(price float64, asOf time.Time, history provider.History) ReturnOnInvestment {
+	return CalculateTotalROI(price, asOf, history, nil)
+}
+
+// CalculateTotalROI is CalculateROI with the dividends paid up to asOf, see TotalROISince.
+func CalculateTotalROI(price float64, asOf time.Time, history provider.History, dividends map[string]Dividend) ReturnOnInvestment {
+	paid := make(map[string]Dividend)
+	for date, dividend := range dividends {
+		if date <= asOf.Format("2006-01-02") {
+			paid[date] = dividend
+		}
+	}
+	var roi ReturnOnInvestment
+	roi.ThreeDays = TotalROISince(price, asOf.AddDate(0, 0, -3), history, paid)
+	roi.OneWeek = TotalROISince(price, asOf.AddDate(0, 0, -7), history, paid)
+	roi.TwoWeeks = TotalROISince(price, asOf.AddDate(0, 0, -14), history, paid)
+	roi.OneMonth = TotalROISince(price, asOf.AddDate(0, -1, 0), history, paid)
+	roi.TwoMonths = TotalROISince(price, asOf.AddDate(0, -2, 0), history, paid)
+	roi.SixMonth = TotalROISince(price, asOf.AddDate(0, -6, 0), history, paid)
+	roi.OneYear = TotalROISince(price, asOf.AddDate(-1, 0, 0), history, paid)
+	roi.TwoYears = TotalROISince(price, asOf.AddDate(-2, 0, 0), history, paid)
+	return roi
+}
+
+// quoteDate is the day of the quote when the provider gives it, now otherwise.
+func quoteDate(quote provider.Quote, now time.Time) time.Time {
+	if quote.Date != "" {
+		if t, err := time.Parse("2006-01-02", quote.Date); err == nil {
+			return t
+		}
+	}
+	return now
+}
@@ -0,0 +1,148 @@
+package portfolio
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/kmorin72/stock/money"
+	"github.com/kmorin72/stock/provider"
+)
+
+type TransactionsData struct {
+	Transactions []struct {
+		ID         string       `json:"id"`
+		Account    string       `json:"account"`
+		Symbol     string       `json:"symbol"`
+		Type       string       `json:"type"`
+		Date       string       `json:"date"`
+		Quantity   float64      `json:"quantity"`
+		Price      money.Amount `json:"price"`
+		Commission money.Amount `json:"commission"`
+		Fees       money.Amount `json:"fees"`
+		Lots       []string     `json:"lots"`
+	} `json:"transactions"`
+}
+
+// StockEvent is one thing that happened to a stock on a day, Costs are the commission and fees of a buy or sell.
+type StockEvent struct {
+	Type      string
+	Quantity  float64
+	Amount    money.Amount
+	SplitTo   int
+	SplitFrom int
+	TxID      string
+	Costs     money.Amount
+}
+
+type Stock struct {
+	Symbol           string
+	Name             string
+	Currency         string
+	Price            money.Amount
+	FiftyTwoWeekHigh money.Amount
+	PriceFetched     time.Time
+	Buys             []Tx
+	Sells            []Tx
+	Dividends        map[string]Dividend
+	Splits           map[string]Split
+	Timeline         map[string][]StockEvent
+	HistoricalData   provider.History
+	TLR              TimeLineResult
+	Lots             LotResult
+	CashFlows        []CashFlow
+	XIRR             float64
+	ROI              ReturnOnInvestment
+	TotalROI         ReturnOnInvestment
+	FetchError       string
+}
+
+type Split struct {
+	Symbol string `json:"symbol"`
+	Date   string `json:"date"`
+	To     int    `json:"to"`
+	From   int    `json:"from"`
+}
+
+type SplitData struct {
+	Splits []Split
+}
+
+// Tx is one buy or sell of the ledger, ID is the id of the transaction or symbol:date#n for the nth of the day.
+// Fees are the ECN, FX and other fees charged on top of the Commission. Lots are the ids of the buys a sell takes
+// its shares from first.
+type Tx struct {
+	ID         string
+	Date       string
+	Quantity   float64
+	Price      money.Amount
+	Commission money.Amount
+	Fees       money.Amount
+	Lots       []string
+}
+
+// Costs are what the trade cost on top of the shares, added to the cost of a buy and taken off the proceeds of a sell.
+func (tx Tx) Costs() money.Amount {
+	return tx.Commission + tx.Fees
+}
+
+type Dividend struct {
+	Date   string       `json:"date"`
+	Amount money.Amount `json:"amount"`
+}
+
+type DividendData struct {
+	Symbol    string     `json:"symbol"`
+	Dividends []Dividend `json:"dividends"`
+}
+
+type ReturnOnInvestment struct {
+	ThreeDays float64
+	OneWeek   float64
+	TwoWeeks  float64
+	OneMonth  float64
+	TwoMonths float64
+	SixMonth  float64
+	OneYear   float64
+	TwoYears  float64
+}
+
+// TimeLineResult has the amounts in the currency of the stock, rounded by its money.Rule as they are paid.
+type TimeLineResult struct {
+	NumberOfShares        float64
+	AveragePrice          money.Amount
+	BookValue             money.Amount
+	DividendPaid          money.Amount
+	DividendPerYear       map[string]money.Amount
+	DividendHikes         int
+	DividendLastYear      money.Amount
+	DividendLastSixMonths money.Amount
+	DividendLastMonth     money.Amount
+	RealizedGains         money.Amount
+	Fees                  money.Amount
+	FeesPerYear           map[string]money.Amount
+}
+
+// newStock is in the currency of the exchange of the symbol until a quote says otherwise.
+func newStock(symbol string) Stock {
+	var tr TimeLineResult
+	var roi ReturnOnInvestment
+	var lots LotResult
+	var history provider.History
+	currency, _ := money.Normalize(provider.CurrencyFromSuffix(symbol))
+	return Stock{symbol, symbol, currency, money.Zero, money.Zero, time.Time{}, nil, nil, make(map[string]Dividend), make(map[string]Split), make(map[string][]StockEvent), history, tr, lots, nil, math.NaN(), roi, roi, ""}
+}
+
+// roundShares drops the float noise left by adding and removing fractional quantities, shares are kept to 6 decimals.
+func roundShares(quantity float64) float64 {
+	return math.Round(quantity*1e6) / 1e6
+}
+
+// FormatShares writes a quantity with only the decimals it has, 100 or 12.3456.
+func FormatShares(quantity float64) string {
+	return strconv.FormatFloat(roundShares(quantity), 'f', -1, 64)
+}
+
+func addStockEvent(stock Stock, date string, event StockEvent) {
+	stock.Timeline[date] = append(stock.Timeline[date], event)
+}
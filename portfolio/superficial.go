@@ -0,0 +1,186 @@
+package portfolio
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kmorin72/stock/money"
+)
+
+// superficialDays is how far before and after a sell a buy of the same shares makes its loss superficial.
+const superficialDays = 30
+
+// AccountTx is a buy or sell of a symbol with the account it was made in, Registered for a TFSA or an RRSP.
+type AccountTx struct {
+	Account    string
+	Registered bool
+	Tx
+}
+
+// Holdings are the buys and sells of a symbol in every account of the household. The CRA looks at the shares bought
+// and held by the taxpayer, in their registered accounts too, to tell if a loss is superficial.
+type Holdings struct {
+	Buys  []AccountTx
+	Sells []AccountTx
+}
+
+// StockHoldings are the holdings of the stock alone, in its account.
+func StockHoldings(account string, stock Stock) Holdings {
+	var holdings Holdings
+	holdings.add(account, false, stock)
+	return holdings
+}
+
+func (holdings *Holdings) add(account string, registered bool, stock Stock) {
+	for _, tx := range stock.Buys {
+		holdings.Buys = append(holdings.Buys, AccountTx{account, registered, tx})
+	}
+	for _, tx := range stock.Sells {
+		holdings.Sells = append(holdings.Sells, AccountTx{account, registered, tx})
+	}
+}
+
+// Holdings are the holdings of the symbol over every account, registered tells which accounts are a TFSA or an RRSP.
+func (h *Household) Holdings(symbol string, registered func(account string) bool) Holdings {
+	var holdings Holdings
+	for _, name := range h.Names() {
+		if stock, isIn := h.Accounts[name].Stocks[symbol]; isIn {
+			holdings.add(name, registered(name), stock)
+		}
+	}
+	return holdings
+}
+
+// ACBAdjustment is a denied loss added to the ACB of the shares of Account on Date, the account that bought back
+// the shares another one sold at a loss.
+type ACBAdjustment struct {
+	Account string
+	Date    string
+	Amount  money.Amount
+}
+
+// replacement is a buy that replaced Shares of the shares sold.
+type replacement struct {
+	AccountTx
+	Shares float64
+}
+
+// superficialShares is how many of the sold shares were replaced: the least of the shares sold, the shares bought
+// within 30 days before or after the sell and the shares still held 30 days after it, over every account.
+// The replaced shares are shared out between the buys of that period like their quantity.
+func superficialShares(holdings Holdings, sell Tx) (float64, []replacement) {
+	date, err := time.Parse("2006-01-02", sell.Date)
+	if err != nil {
+		return 0, nil
+	}
+	from := date.AddDate(0, 0, -superficialDays).Format("2006-01-02")
+	to := date.AddDate(0, 0, superficialDays).Format("2006-01-02")
+
+	bought := 0.0
+	replacements := []replacement{}
+	for _, tx := range holdings.Buys {
+		if tx.Date >= from && tx.Date <= to {
+			bought += tx.Quantity
+			replacements = append(replacements, replacement{AccountTx: tx})
+		}
+	}
+	if len(replacements) == 0 {
+		return 0, nil
+	}
+
+	held := 0.0
+	for _, tx := range holdings.Buys {
+		if tx.Date <= to {
+			held += tx.Quantity
+		}
+	}
+	for _, tx := range holdings.Sells {
+		if tx.Date <= to {
+			held -= tx.Quantity
+		}
+	}
+	replaced := roundShares(minShares(sell.Quantity, minShares(bought, held)))
+	if replaced <= 0 {
+		return 0, nil
+	}
+	for i := range replacements {
+		replacements[i].Shares = replaced * replacements[i].Quantity / bought
+	}
+	return replaced, replacements
+}
+
+func minShares(a float64, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// GetSuperficialLossesString lists the sells of year, every year when it is empty, whose loss was denied with the buys
+// that replaced the shares, the part of the loss added to the other accounts and the part lost for good to the
+// registered accounts.
+func GetSuperficialLossesString(results []ACBResult, year string) string {
+	str := ""
+	for _, result := range results {
+		for _, d := range result.Dispositions {
+			if d.Denied.IsZero() || year != "" && d.Date[:4] != year {
+				continue
+			}
+			loss := d.Gain - d.Denied
+			str += fmt.Sprintf("  %-10s %s  %9s shares  loss %12s  denied %12s  replaced by %v", accountSymbol(result.Account, d.Symbol), d.Date, FormatShares(d.Quantity),
+				loss.In(result.Currency), d.Denied.In(result.Currency), d.ReplacedBy)
+			for _, moved := range d.MovedTo {
+				str += fmt.Sprintf("  %s added to %s", moved.Amount.In(result.Currency), accountSymbol(moved.Account, d.Symbol))
+			}
+			if !d.Lost.IsZero() {
+				str += fmt.Sprintf("  %s lost to a registered account", d.Lost.In(result.Currency))
+			}
+			str += "\n"
+		}
+	}
+	if str == "" {
+		return ""
+	}
+	return "\nSuperficial losses (denied and added to the adjusted cost base of the shares bought back in the account that bought them, lost when bought back in a TFSA or an RRSP)\n" + str
+}
+
+// deniedLoss is the part of the loss of d that is superficial, zero when d is a gain or the shares were not replaced.
+// It is shared out like the replaced shares: the part of the buys of another account is moved to its ACB and the part
+// of the registered accounts is lost for good, as the shares bought back there have no cost base.
+func deniedLoss(holdings Holdings, account string, sell Tx, d *Disposition, currency string) money.Amount {
+	if d.Gain.Sign() >= 0 {
+		return money.Zero
+	}
+	replaced, replacements := superficialShares(holdings, sell)
+	if replaced <= 0 {
+		return money.Zero
+	}
+	denied := (-d.Gain).MulFloat(replaced).DivFloat(sell.Quantity).RoundTo(currency)
+	for _, r := range replacements {
+		d.ReplacedBy = append(d.ReplacedBy, accountSymbol(r.Account, r.ID))
+		if r.Account == account {
+			continue
+		}
+		part := denied.MulFloat(r.Shares).DivFloat(replaced).RoundTo(currency)
+		if r.Registered {
+			d.Lost += part
+			continue
+		}
+		// a buy before the sell only gets the loss once it is denied
+		date := r.Date
+		if date < sell.Date {
+			date = sell.Date
+		}
+		d.MovedTo = append(d.MovedTo, ACBAdjustment{r.Account, date, part})
+	}
+	return denied
+}
+
+// kept is the part of the denied loss of d added to the ACB of the account that sold.
+func (d Disposition) kept() money.Amount {
+	kept := d.Denied - d.Lost
+	for _, moved := range d.MovedTo {
+		kept -= moved.Amount
+	}
+	return kept
+}
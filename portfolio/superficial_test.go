@@ -0,0 +1,181 @@
+package portfolio
+
+import (
+	"testing"
+
+	"github.com/kmorin72/stock/money"
+)
+
+// trade adds a buy or sell of the symbol to the account of the household, in CAD without costs.
+func trade(h *Household, account string, kind string, symbol string, date string, quantity float64, price float64) {
+	p, isIn := h.Accounts[account]
+	if !isIn {
+		p = New()
+		p.Account = account
+		h.Accounts[account] = p
+	}
+	stock := p.Stock(symbol)
+	stock.Currency = "CAD"
+	id := accountSymbol(account, symbol+":"+date)
+	tx := Tx{ID: id, Date: date, Quantity: quantity, Price: money.FromFloat(price)}
+	if kind == "buy" {
+		stock.Buys = append(stock.Buys, tx)
+	} else {
+		stock.Sells = append(stock.Sells, tx)
+	}
+	addStockEvent(stock, date, StockEvent{kind, quantity, tx.Price, 0, 0, id, money.Zero})
+	p.Stocks[symbol] = stock
+}
+
+func newTestHousehold() *Household {
+	return &Household{make(map[string]*Portfolio)}
+}
+
+func registeredAccounts(accounts ...string) func(string) bool {
+	return func(account string) bool {
+		for _, a := range accounts {
+			if a == account {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// acbOf is the result of the symbol in the account.
+func acbOf(t *testing.T, results []ACBResult, account string, symbol string) ACBResult {
+	for _, result := range results {
+		if result.Account == account && result.Symbol == symbol {
+			return result
+		}
+	}
+	t.Fatalf("no result for %s/%s", account, symbol)
+	return ACBResult{}
+}
+
+func TestSuperficialLossMovedToTheAccountThatBoughtBack(t *testing.T) {
+	h := newTestHousehold()
+	trade(h, "cash", "buy", "BCE.TO", "2018-01-10", 100, 50)
+	trade(h, "cash", "sell", "BCE.TO", "2018-06-01", 100, 40)
+	trade(h, "margin", "buy", "BCE.TO", "2018-06-19", 100, 41)
+	trade(h, "margin", "sell", "BCE.TO", "2019-03-01", 100, 45)
+
+	results, missing := h.ComputeACB(nil, registeredAccounts())
+	if len(missing) > 0 {
+		t.Fatal(missing)
+	}
+	cash := acbOf(t, results, "cash", "BCE.TO").Dispositions[0]
+	if cash.Denied != money.FromInt(1000) || !cash.Gain.IsZero() {
+		t.Errorf("cash denied %s with a gain of %s, want 1000.00 and 0.00", cash.Denied.In("CAD"), cash.Gain.In("CAD"))
+	}
+	if len(cash.MovedTo) != 1 || cash.MovedTo[0] != (ACBAdjustment{"margin", "2018-06-19", money.FromInt(1000)}) {
+		t.Errorf("cash moved %v, want 1000.00 to margin on 2018-06-19", cash.MovedTo)
+	}
+	margin := acbOf(t, results, "margin", "BCE.TO").Dispositions[0]
+	if margin.ACB != money.FromInt(5100) || margin.Gain != money.FromInt(-600) {
+		t.Errorf("margin ACB %s with a gain of %s, want 5100.00 and -600.00", margin.ACB.In("CAD"), margin.Gain.In("CAD"))
+	}
+}
+
+func TestSuperficialLossWindow(t *testing.T) {
+	tests := []struct {
+		name   string
+		buy    string
+		sell   string
+		denied bool
+	}{
+		{"30 days before", "2018-05-02", "", true},
+		{"31 days before", "2018-05-01", "", false},
+		{"30 days after", "2018-07-01", "", true},
+		{"31 days after", "2018-07-02", "", false},
+		{"sold again within 30 days", "2018-06-10", "2018-06-20", false},
+		{"sold again after 30 days", "2018-06-10", "2018-07-02", true},
+	}
+	for _, test := range tests {
+		h := newTestHousehold()
+		trade(h, "cash", "buy", "BCE.TO", "2018-01-10", 100, 50)
+		trade(h, "cash", "buy", "BCE.TO", test.buy, 100, 41)
+		trade(h, "cash", "sell", "BCE.TO", "2018-06-01", 100, 40)
+		if test.sell != "" {
+			trade(h, "cash", "sell", "BCE.TO", test.sell, 100, 39)
+		}
+
+		results, missing := h.ComputeACB(nil, registeredAccounts())
+		if len(missing) > 0 {
+			t.Fatal(missing)
+		}
+		d := acbOf(t, results, "cash", "BCE.TO").Dispositions[0]
+		if test.denied && (d.Denied.IsZero() || !d.Gain.IsZero()) {
+			t.Errorf("%s: denied %s with a gain of %s, want the whole loss denied", test.name, d.Denied.In("CAD"), d.Gain.In("CAD"))
+		}
+		if !test.denied && (!d.Denied.IsZero() || d.Gain.Sign() >= 0) {
+			t.Errorf("%s: denied %s with a gain of %s, want the loss allowed", test.name, d.Denied.In("CAD"), d.Gain.In("CAD"))
+		}
+	}
+}
+
+func TestSuperficialLossOfPartOfTheShares(t *testing.T) {
+	h := newTestHousehold()
+	trade(h, "cash", "buy", "BCE.TO", "2018-01-10", 100, 50)
+	trade(h, "cash", "sell", "BCE.TO", "2018-06-01", 100, 40)
+	trade(h, "cash", "buy", "BCE.TO", "2018-06-19", 40, 41)
+
+	results, _ := h.ComputeACB(nil, registeredAccounts())
+	result := acbOf(t, results, "cash", "BCE.TO")
+	d := result.Dispositions[0]
+	// 40 of the 100 shares were bought back
+	if d.Denied != money.FromInt(400) || d.Gain != money.FromInt(-600) {
+		t.Errorf("denied %s with a gain of %s, want 400.00 and -600.00", d.Denied.In("CAD"), d.Gain.In("CAD"))
+	}
+	if result.Shares != 40 || result.ACB != money.FromInt(2040) {
+		t.Errorf("ACB of %v shares is %s, want 2040.00 for 40", result.Shares, result.ACB.In("CAD"))
+	}
+}
+
+func TestSuperficialLossLostToARegisteredAccount(t *testing.T) {
+	h := newTestHousehold()
+	trade(h, "cash", "buy", "BCE.TO", "2018-01-10", 100, 50)
+	trade(h, "cash", "sell", "BCE.TO", "2018-06-01", 100, 40)
+	trade(h, "tfsa", "buy", "BCE.TO", "2018-06-19", 60, 41)
+	trade(h, "cash", "buy", "BCE.TO", "2018-06-20", 40, 41)
+
+	results, _ := h.ComputeACB(nil, registeredAccounts("tfsa"))
+	for _, result := range results {
+		if result.Account == "tfsa" {
+			t.Errorf("the registered account has an ACB")
+		}
+	}
+	result := acbOf(t, results, "cash", "BCE.TO")
+	d := result.Dispositions[0]
+	if d.Denied != money.FromInt(1000) || d.Lost != money.FromInt(600) || len(d.MovedTo) != 0 {
+		t.Errorf("denied %s, lost %s and moved %v, want 1000.00 with 600.00 lost", d.Denied.In("CAD"), d.Lost.In("CAD"), d.MovedTo)
+	}
+	// the 400 left of the loss goes to the 40 shares of cash
+	if result.ACB != money.FromInt(2040) {
+		t.Errorf("ACB is %s, want 2040.00", result.ACB.In("CAD"))
+	}
+}
+
+func TestSuperficialLossMovedBeforeTheSell(t *testing.T) {
+	h := newTestHousehold()
+	trade(h, "cash", "buy", "BCE.TO", "2018-01-10", 100, 50)
+	trade(h, "margin", "buy", "BCE.TO", "2018-05-20", 50, 41)
+	trade(h, "cash", "sell", "BCE.TO", "2018-06-01", 100, 40)
+	trade(h, "cash", "buy", "BCE.TO", "2018-06-19", 50, 41)
+	trade(h, "margin", "sell", "BCE.TO", "2019-03-01", 50, 45)
+
+	results, _ := h.ComputeACB(nil, registeredAccounts())
+	cash := acbOf(t, results, "cash", "BCE.TO")
+	d := cash.Dispositions[0]
+	// the buy of margin only gets its half once the loss is denied, on the day of the sell
+	if len(d.MovedTo) != 1 || d.MovedTo[0] != (ACBAdjustment{"margin", "2018-06-01", money.FromInt(500)}) {
+		t.Errorf("cash moved %v, want 500.00 to margin on 2018-06-01", d.MovedTo)
+	}
+	if cash.ACB != money.FromInt(2550) {
+		t.Errorf("cash ACB is %s, want 2550.00", cash.ACB.In("CAD"))
+	}
+	margin := acbOf(t, results, "margin", "BCE.TO").Dispositions[0]
+	if margin.ACB != money.FromInt(2550) || margin.Gain != money.FromInt(-300) {
+		t.Errorf("margin ACB %s with a gain of %s, want 2550.00 and -300.00", margin.ACB.In("CAD"), margin.Gain.In("CAD"))
+	}
+}
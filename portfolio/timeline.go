@@ -0,0 +1,107 @@
+package portfolio
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kmorin72/stock/money"
+)
+
+// ProcessTimeline replays the events of the stock in date order and returns the position and dividends it ends up with.
+// The dividend windows are counted back from now. The book value is kept exact, the cost of a trade and each dividend
+// payout are rounded by the rule of the currency of the stock, like the broker does. The commission and fees are
+// added to the book value of a buy and taken off the proceeds of a sell.
+func ProcessTimeline(stock Stock, now time.Time) TimeLineResult {
+	tr, _ := ProcessTimelineIn(stock, now, stock.Currency, nil)
+	return tr
+}
+
+// ProcessTimelineIn is ProcessTimeline with the amounts converted into currency at the rate of the day of each event,
+// rounded by the rule of currency. A nil rate leaves the amounts in the currency of the stock.
+// It fails with the first rate that is missing.
+func ProcessTimelineIn(stock Stock, now time.Time, currency string, rate func(date string) (float64, error)) (TimeLineResult, error) {
+	var err error
+	convert := func(amount money.Amount, date string) money.Amount {
+		amount = amount.RoundTo(stock.Currency)
+		if rate == nil || amount.IsZero() {
+			return amount
+		}
+		r, rateErr := rate(date)
+		if rateErr != nil && err == nil {
+			err = rateErr
+		}
+		return amount.MulFloat(r).RoundTo(currency)
+	}
+	firstPurchaseFound := false
+	LastDividendAmount := money.Zero
+	hike := money.FromFloat(.005)
+	tr := TimeLineResult{DividendPerYear: make(map[string]money.Amount), FeesPerYear: make(map[string]money.Amount)}
+
+	oneMonthAgo := now.AddDate(0, -1, 0)
+	sixMonthAgo := now.AddDate(0, -6, 0)
+	oneYearAgo := now.AddDate(-1, 0, 0)
+	for _, key := range TimelineDates(stock) {
+		events := stock.Timeline[key]
+		for _, event := range events {
+			if !event.Costs.IsZero() {
+				costs := convert(event.Costs, key)
+				tr.Fees += costs
+				tr.FeesPerYear[key[:4]] += costs
+			}
+			switch event.Type {
+			case "buy":
+				firstPurchaseFound = true
+				tr.BookValue += convert(event.Amount.MulFloat(event.Quantity), key) + convert(event.Costs, key)
+				tr.NumberOfShares = roundShares(tr.NumberOfShares + event.Quantity)
+				tr.AveragePrice = tr.BookValue.DivFloat(tr.NumberOfShares)
+			case "sell":
+				proceeds := convert(event.Amount.MulFloat(event.Quantity), key) - convert(event.Costs, key)
+				cost := tr.BookValue
+				if event.Quantity < tr.NumberOfShares {
+					cost = tr.BookValue.MulFloat(event.Quantity).DivFloat(tr.NumberOfShares).RoundTo(currency)
+				}
+				tr.RealizedGains += proceeds - cost
+				tr.BookValue -= cost
+				tr.NumberOfShares = roundShares(tr.NumberOfShares - event.Quantity)
+			case "split":
+				// the transactions are already adjusted for the splits when they are loaded
+			case "dividend":
+				if firstPurchaseFound {
+					date, _ := time.Parse("2006-01-02", key)
+					year := (strings.Split(key, "-"))[0]
+					payout := convert(event.Amount.MulFloat(tr.NumberOfShares), key)
+					tr.DividendPerYear[year] += payout
+					if date.After(oneYearAgo) {
+						tr.DividendLastYear += payout
+					}
+					if date.After(sixMonthAgo) {
+						tr.DividendLastSixMonths += payout
+					}
+					if date.After(oneMonthAgo) {
+						tr.DividendLastMonth += payout
+					}
+					tr.DividendPaid += payout
+					if event.Amount > LastDividendAmount+hike {
+						tr.DividendHikes++
+						LastDividendAmount = event.Amount
+					}
+				}
+			default:
+				fmt.Println("ERROR - unexpected stock event type.")
+			}
+		}
+	}
+	return tr, err
+}
+
+// TimelineDates are the dates of the timeline of the stock, oldest first.
+func TimelineDates(stock Stock) []string {
+	var keys []string
+	for k := range stock.Timeline {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
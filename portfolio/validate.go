@@ -0,0 +1,145 @@
+package portfolio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Validate checks the splits, dividend and transaction files without loading them into a portfolio,
+// and returns every problem found, one line each. When accounts are given, the transactions can only name those.
+func Validate(dataDir string, transactionsFile string, accounts []string) []string {
+	problems := []string{}
+
+	splitsFile := filepath.Join(dataDir, "splits.json")
+	var splitData SplitData
+	if err := readJSON(splitsFile, &splitData); err != nil {
+		problems = append(problems, err.Error())
+	}
+	for i, split := range splitData.Splits {
+		where := fmt.Sprintf("%s: split %d (%s)", splitsFile, i+1, split.Symbol)
+		if split.Symbol == "" {
+			problems = append(problems, where+" - no symbol")
+		}
+		if !validDate(split.Date) {
+			problems = append(problems, where+" - invalid date "+split.Date)
+		}
+		if split.To <= 0 || split.From <= 0 {
+			problems = append(problems, fmt.Sprintf("%s - invalid ratio %d:%d", where, split.To, split.From))
+		}
+	}
+
+	filepath.Walk(filepath.Join(dataDir, "dividends"), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			problems = append(problems, err.Error())
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		var dividendData DividendData
+		if err := readJSON(path, &dividendData); err != nil {
+			problems = append(problems, err.Error())
+			return nil
+		}
+		if dividendData.Symbol == "" {
+			problems = append(problems, path+" - no symbol")
+		}
+		for i, dividend := range dividendData.Dividends {
+			where := fmt.Sprintf("%s: dividend %d", path, i+1)
+			if !validDate(dividend.Date) {
+				if _, err := time.Parse("01/02/06", dividend.Date); err != nil {
+					problems = append(problems, where+" - invalid date "+dividend.Date)
+				}
+			}
+			if dividend.Amount.Sign() <= 0 {
+				problems = append(problems, fmt.Sprintf("%s - invalid amount %v", where, dividend.Amount))
+			}
+		}
+		return nil
+	})
+
+	var transactionData TransactionsData
+	if err := readJSON(transactionsFile, &transactionData); err != nil {
+		problems = append(problems, err.Error())
+	}
+	known := make(map[string]bool)
+	for _, account := range accounts {
+		known[account] = true
+	}
+	ids := make(map[string]bool)
+	buys := make(map[string]bool)
+	perDay := make(map[string]int)
+	for i, transaction := range transactionData.Transactions {
+		where := fmt.Sprintf("%s: transaction %d (%s %s)", transactionsFile, i+1, transaction.Symbol, transaction.Date)
+		if transaction.ID != "" {
+			if ids[transaction.ID] {
+				problems = append(problems, where+" - duplicate id "+transaction.ID)
+			}
+			ids[transaction.ID] = true
+		}
+		if transaction.Symbol == "" {
+			problems = append(problems, where+" - no symbol")
+		}
+		if len(accounts) > 0 && transaction.Account != "" && !known[transaction.Account] {
+			problems = append(problems, where+" - account "+transaction.Account+" is not in the config")
+		}
+		if transaction.Type != "buy" && transaction.Type != "sell" {
+			problems = append(problems, where+" - type is not buy or sell: "+transaction.Type)
+		}
+		if !validDate(transaction.Date) {
+			problems = append(problems, where+" - invalid date "+transaction.Date)
+		}
+		if transaction.Quantity <= 0 {
+			problems = append(problems, fmt.Sprintf("%s - invalid quantity %v", where, transaction.Quantity))
+		}
+		if transaction.Price.Sign() < 0 {
+			problems = append(problems, fmt.Sprintf("%s - invalid price %v", where, transaction.Price))
+		}
+		if transaction.Commission.Sign() < 0 {
+			problems = append(problems, fmt.Sprintf("%s - invalid commission %v", where, transaction.Commission))
+		}
+		if transaction.Fees.Sign() < 0 {
+			problems = append(problems, fmt.Sprintf("%s - invalid fees %v", where, transaction.Fees))
+		}
+
+		// the lots of a sell are the ids of buys of the same symbol before it, as LoadTransactions numbers them
+		day := transaction.Symbol + ":" + transaction.Date
+		perDay[day]++
+		id := transaction.ID
+		if id == "" {
+			id = fmt.Sprintf("%s#%d", day, perDay[day])
+		}
+		if transaction.Type == "buy" {
+			buys[transaction.Account+" "+transaction.Symbol+" "+id] = true
+			if len(transaction.Lots) > 0 {
+				problems = append(problems, where+" - only a sell can name lots")
+			}
+		}
+		for _, lot := range transaction.Lots {
+			if !buys[transaction.Account+" "+transaction.Symbol+" "+lot] {
+				problems = append(problems, where+" - lot "+lot+" is not a buy of "+transaction.Symbol+" listed before it")
+			}
+		}
+	}
+	return problems
+}
+
+func readJSON(file string, v interface{}) error {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("%s - %s", file, err.Error())
+	}
+	return nil
+}
+
+func validDate(date string) bool {
+	_, err := time.Parse("2006-01-02", date)
+	return err == nil
+}
@@ -0,0 +1,278 @@
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kmorin72/stock/money"
+	"github.com/kmorin72/stock/provider"
+)
+
+// ValuePoint is what a position or a portfolio was worth at the close of a day. Flow is the money put in that day,
+// the buys less the sells and the dividends paid out, and Return is the change of value of the day without it:
+// the flows are counted at the end of the day, the shares bought only earn from the next one. On the first day,
+// or when nothing was held the day before, Return is the close against the cost of the buys.
+type ValuePoint struct {
+	Date   string
+	Value  money.Amount
+	Flow   money.Amount
+	Return float64
+}
+
+// TimeWeightedReturn chains the Return of the days of each period, in %, so the money put in or taken out does
+// not count. A period is NaN when the series does not go back to its start, Inception is since the first day.
+type TimeWeightedReturn struct {
+	OneMonth    float64
+	ThreeMonths float64
+	SixMonths   float64
+	YearToDate  float64
+	OneYear     float64
+	ThreeYears  float64
+	FiveYears   float64
+	Inception   float64
+}
+
+// valuedStock is a stock as ValueSeries goes through the days, next is its next timeline date and day its next close.
+type valuedStock struct {
+	stock    Stock
+	days     []provider.Day
+	dates    []string
+	firstBuy string
+	next     int
+	day      int
+	shares   float64
+	close    float64
+	started  bool
+}
+
+// firstBuy is the date of the first buy of the timeline, the dividends before it were not received.
+func firstBuy(stock Stock, dates []string) string {
+	for _, date := range dates {
+		for _, event := range stock.Timeline[date] {
+			if event.Type == "buy" {
+				return date
+			}
+		}
+	}
+	return ""
+}
+
+// ValueSeries values the stocks at the close of every day of their history from their first buy, in currency at the
+// rate of the day when c is given. A stock is worth its last close on the days its exchange is closed. The stocks
+// without history or without the rates are left out, and a stock whose history starts after its first buy is put in
+// at its value on its first close.
+func ValueSeries(stocks []Stock, currency string, c *Converter) []ValuePoint {
+	rate := func(stock Stock, date string) float64 {
+		if c == nil {
+			return 1
+		}
+		r, _ := c.Rate(stock.Currency, date)
+		return r
+	}
+	convert := func(stock Stock, amount money.Amount, date string) money.Amount {
+		amount = amount.RoundTo(stock.Currency)
+		if c == nil {
+			return amount
+		}
+		return amount.MulFloat(rate(stock, date)).RoundTo(currency)
+	}
+
+	valued := []*valuedStock{}
+	seen := make(map[string]bool)
+	dates := []string{}
+	for _, stock := range stocks {
+		timeline := TimelineDates(stock)
+		first := firstBuy(stock, timeline)
+		if first == "" || len(stock.HistoricalData.Days) == 0 {
+			continue
+		}
+		if c != nil {
+			if _, err := c.Rate(stock.Currency, first); err != nil {
+				continue
+			}
+		}
+		// the history is most recent first
+		days := make([]provider.Day, len(stock.HistoricalData.Days))
+		for i, day := range stock.HistoricalData.Days {
+			days[len(days)-1-i] = day
+		}
+		for _, day := range days {
+			if day.Date >= first && !seen[day.Date] {
+				seen[day.Date] = true
+				dates = append(dates, day.Date)
+			}
+		}
+		valued = append(valued, &valuedStock{stock: stock, days: days, dates: timeline, firstBuy: first})
+	}
+	sort.Strings(dates)
+
+	series := []ValuePoint{}
+	previous := money.Zero
+	for _, date := range dates {
+		value, in, out := money.Zero, money.Zero, money.Zero
+		for _, s := range valued {
+			stockIn, stockOut := money.Zero, money.Zero
+			for ; s.next < len(s.dates) && s.dates[s.next] <= date; s.next++ {
+				key := s.dates[s.next]
+				for _, event := range s.stock.Timeline[key] {
+					switch event.Type {
+					case "buy":
+						stockIn += convert(s.stock, event.Amount.MulFloat(event.Quantity), key) + convert(s.stock, event.Costs, key)
+						s.shares = roundShares(s.shares + event.Quantity)
+					case "sell":
+						stockOut += convert(s.stock, event.Amount.MulFloat(event.Quantity), key) - convert(s.stock, event.Costs, key)
+						s.shares = roundShares(s.shares - event.Quantity)
+					case "dividend":
+						stockOut += convert(s.stock, event.Amount.MulFloat(s.shares), key)
+					}
+				}
+			}
+			for ; s.day < len(s.days) && s.days[s.day].Date <= date; s.day++ {
+				s.close = s.days[s.day].Close
+			}
+			if s.close == 0 {
+				continue
+			}
+			worth := convert(s.stock, money.FromFloat(s.close).MulFloat(s.shares), date)
+			if !s.started {
+				s.started = true
+				if s.days[0].Date > s.firstBuy {
+					stockIn, stockOut = worth, money.Zero
+				}
+			}
+			value += worth
+			in += stockIn
+			out += stockOut
+		}
+		point := ValuePoint{date, value, in - out, 0}
+		if previous > 0 {
+			point.Return = (value-in+out).Float64()/previous.Float64() - 1
+		} else if in > 0 {
+			point.Return = (value+out).Float64()/in.Float64() - 1
+		}
+		series = append(series, point)
+		previous = value
+	}
+	return series
+}
+
+// StockValueSeries is the ValueSeries of the stock in its currency.
+func StockValueSeries(stock Stock) []ValuePoint {
+	return ValueSeries([]Stock{stock}, stock.Currency, nil)
+}
+
+// ValueSeries is the ValueSeries of the stocks of each currency.
+func (p *Portfolio) ValueSeries() map[string][]ValuePoint {
+	stocks := []Stock{}
+	for _, symbol := range p.Symbols() {
+		stocks = append(stocks, p.Stocks[symbol])
+	}
+	return seriesByCurrency(stocks)
+}
+
+// ValueSeries is the ValueSeries of the stocks of every account by currency, the household value.
+func (h *Household) ValueSeries() map[string][]ValuePoint {
+	return seriesByCurrency(h.stocks())
+}
+
+// ValueSeriesIn is the value of the whole household in the currency of c.
+func (h *Household) ValueSeriesIn(c *Converter) []ValuePoint {
+	return ValueSeries(h.stocks(), c.Currency, c)
+}
+
+// StockValueSeries is the ValueSeries of the symbol over every account that holds it.
+func (h *Household) StockValueSeries(symbol string) []ValuePoint {
+	stocks := []Stock{}
+	currency := ""
+	for _, name := range h.Names() {
+		if stock, isIn := h.Accounts[name].Stocks[symbol]; isIn {
+			stocks = append(stocks, stock)
+			currency = stock.Currency
+		}
+	}
+	return ValueSeries(stocks, currency, nil)
+}
+
+// stocks are the stocks of every account, with their timeline.
+func (h *Household) stocks() []Stock {
+	stocks := []Stock{}
+	for _, name := range h.Names() {
+		p := h.Accounts[name]
+		for _, symbol := range p.Symbols() {
+			stocks = append(stocks, p.Stocks[symbol])
+		}
+	}
+	return stocks
+}
+
+func seriesByCurrency(stocks []Stock) map[string][]ValuePoint {
+	byCurrency := make(map[string][]Stock)
+	for _, stock := range stocks {
+		byCurrency[stock.Currency] = append(byCurrency[stock.Currency], stock)
+	}
+	series := make(map[string][]ValuePoint)
+	for currency, currencyStocks := range byCurrency {
+		series[currency] = ValueSeries(currencyStocks, currency, nil)
+	}
+	return series
+}
+
+// TWRSince chains the Return of the days after from, in %. NaN when the series starts after from or has no day after it.
+func TWRSince(series []ValuePoint, from string) float64 {
+	if len(series) == 0 || series[0].Date > from || series[len(series)-1].Date <= from {
+		return math.NaN()
+	}
+	growth := 1.0
+	for _, point := range series {
+		if point.Date > from {
+			growth *= 1 + point.Return
+		}
+	}
+	return (growth - 1) * 100
+}
+
+// CalculateTWR fills every period of TimeWeightedReturn, counting back from asOf.
+func CalculateTWR(series []ValuePoint, asOf time.Time) TimeWeightedReturn {
+	since := func(t time.Time) float64 {
+		return TWRSince(series, t.Format("2006-01-02"))
+	}
+	var twr TimeWeightedReturn
+	twr.OneMonth = since(asOf.AddDate(0, -1, 0))
+	twr.ThreeMonths = since(asOf.AddDate(0, -3, 0))
+	twr.SixMonths = since(asOf.AddDate(0, -6, 0))
+	twr.YearToDate = since(time.Date(asOf.Year(), 1, 1, 0, 0, 0, 0, asOf.Location()).AddDate(0, 0, -1))
+	twr.OneYear = since(asOf.AddDate(-1, 0, 0))
+	twr.ThreeYears = since(asOf.AddDate(-3, 0, 0))
+	twr.FiveYears = since(asOf.AddDate(-5, 0, 0))
+	twr.Inception = math.NaN()
+	if len(series) > 0 {
+		// from the day before, the first day has the return of the first buy
+		start := addDays(series[0].Date, -1)
+		twr.Inception = TWRSince(append([]ValuePoint{{Date: start}}, series...), start)
+	}
+	return twr
+}
+
+func GetTWRHeader() string {
+	return fmt.Sprintf("%-12s %10s %10s %10s %10s %10s %10s %10s %10s\n", "", "1m", "3m", "6m", "YTD", "1y", "3y", "5y", "Inception")
+}
+
+// GetTWRRow has the returns of twr under GetTWRHeader, n/a for the periods the series does not cover.
+func GetTWRRow(name string, twr TimeWeightedReturn) string {
+	return fmt.Sprintf("%-12s %10s %10s %10s %10s %10s %10s %10s %10s\n", name, formatRate(twr.OneMonth), formatRate(twr.ThreeMonths),
+		formatRate(twr.SixMonths), formatRate(twr.YearToDate), formatRate(twr.OneYear), formatRate(twr.ThreeYears),
+		formatRate(twr.FiveYears), formatRate(twr.Inception))
+}
+
+// GetValueSeriesCSV has a row per day of the series, Index is 100 grown by the returns since the first day.
+func GetValueSeriesCSV(series []ValuePoint, currency string) string {
+	str := "Date, Value, Flow, Return, Index\n"
+	index := 100.0
+	for _, point := range series {
+		index *= 1 + point.Return
+		str += fmt.Sprintf("%s, %s, %s, %.4f%%, %.4f\n", point.Date, point.Value.In(currency), point.Flow.In(currency), point.Return*100, index)
+	}
+	return str
+}
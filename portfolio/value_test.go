@@ -0,0 +1,56 @@
+package portfolio
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/kmorin72/stock/money"
+	"github.com/kmorin72/stock/provider"
+)
+
+func TestValueSeriesBuysAtTheEndOfTheDay(t *testing.T) {
+	stock := newStock("XYZ")
+	stock.Currency = "USD"
+	addStockEvent(stock, "2019-01-02", StockEvent{"buy", 100, money.FromInt(10), 0, 0, "XYZ:2019-01-02#1", money.Zero})
+	addStockEvent(stock, "2019-01-03", StockEvent{"buy", 100, money.FromInt(11), 0, 0, "XYZ:2019-01-03#1", money.Zero})
+	addStockEvent(stock, "2019-01-04", StockEvent{"dividend", 0, money.FromInt(1), 0, 0, "", money.Zero})
+	// most recent first
+	stock.HistoricalData.Days = []provider.Day{{Date: "2019-01-04", Close: 12.1}, {Date: "2019-01-03", Close: 11}, {Date: "2019-01-02", Close: 10}}
+
+	series := StockValueSeries(stock)
+	want := []float64{0, 0.10, 2620.0/2200 - 1}
+	if len(series) != len(want) {
+		t.Fatalf("%d days, want %d", len(series), len(want))
+	}
+	for i, point := range series {
+		if math.Abs(point.Return-want[i]) > 1e-9 {
+			t.Errorf("%s returned %.4f%%, want %.4f%%", point.Date, point.Return*100, want[i]*100)
+		}
+	}
+	if flow := series[1].Flow; flow != money.FromInt(1100) {
+		t.Errorf("flow of the second buy is %s, want 1100.00", flow.In("USD"))
+	}
+
+	twr := CalculateTWR(series, time.Date(2019, 1, 4, 0, 0, 0, 0, time.UTC))
+	if math.Abs(twr.Inception-31) > 1e-9 {
+		t.Errorf("inception is %.4f%%, want 31%%", twr.Inception)
+	}
+}
+
+func TestTWRAfterTheEndOfTheSeries(t *testing.T) {
+	series := []ValuePoint{{Date: "2020-04-30", Return: 0.01}, {Date: "2020-05-01", Return: 0.02}}
+
+	twr := CalculateTWR(series, time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+	for name, r := range map[string]float64{"1m": twr.OneMonth, "3m": twr.ThreeMonths, "6m": twr.SixMonths, "YTD": twr.YearToDate, "1y": twr.OneYear} {
+		if !math.IsNaN(r) {
+			t.Errorf("%s is %.2f%% for a series that ended before it, want n/a", name, r)
+		}
+	}
+	if math.Abs(twr.Inception-(1.01*1.02-1)*100) > 1e-9 {
+		t.Errorf("inception is %.4f%%, want 3.02%%", twr.Inception)
+	}
+	if r := TWRSince(series, "2020-05-01"); !math.IsNaN(r) {
+		t.Errorf("since the last day is %.2f%%, want n/a", r)
+	}
+}
@@ -0,0 +1,191 @@
+package portfolio
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kmorin72/stock/money"
+)
+
+// washDays is how far before and after a loss sale a buy of the same stock makes it a wash sale.
+const washDays = 30
+
+// washSale is a loss sale with shares not replaced yet, a buy of the next 30 days can still replace them.
+type washSale struct {
+	sale   int
+	shares float64
+}
+
+// washSales are the loss sales waiting for a buy, as ComputeLots goes through the timeline.
+type washSales struct {
+	pending []washSale
+}
+
+// sold replaces the shares of the loss sale i with the open lots bought in the 30 days before it,
+// the shares left wait for the buys of the 30 days after.
+func (w *washSales) sold(stock Stock, result *LotResult, i int) {
+	sale := result.Sales[i]
+	if sale.Gain.Sign() >= 0 {
+		return
+	}
+	from := addDays(sale.Date, -washDays)
+	shares := sale.Quantity
+	for j := 0; j < len(result.Lots) && shares > 0; j++ {
+		lot := result.Lots[j]
+		if lot.replacement || lot.Remaining <= 0 || lot.ID == sale.LotID || lot.Date < from || lot.Date > sale.Date {
+			continue
+		}
+		shares = roundShares(shares - replace(stock, result, i, j, shares))
+	}
+	if shares > 0 {
+		w.pending = append(w.pending, washSale{i, shares})
+	}
+}
+
+// bought makes the new lot j replace the shares of the loss sales of the last 30 days, oldest sale first.
+func (w *washSales) bought(stock Stock, result *LotResult, j int) {
+	pending := []washSale{}
+	for _, wash := range w.pending {
+		if result.Lots[j].Date > addDays(result.Sales[wash.sale].Date, washDays) {
+			continue
+		}
+		if !result.Lots[j].replacement {
+			wash.shares = roundShares(wash.shares - replace(stock, result, wash.sale, j, wash.shares))
+			// the part of the lot that did not replace shares was split after it
+			if result.Lots[j].replacement && j+1 < len(result.Lots) {
+				j++
+			}
+		}
+		if wash.shares > 0 {
+			pending = append(pending, wash)
+		}
+	}
+	w.pending = pending
+}
+
+// replace makes up to shares of lot j replace shares of the loss sale i, splitting the lot when it has more.
+// The loss of those shares is disallowed and added to the basis of the lot, which keeps the holding period of the
+// shares sold. It returns how many shares were replaced.
+func replace(stock Stock, result *LotResult, i int, j int, shares float64) float64 {
+	sale := &result.Sales[i]
+	lot := result.Lots[j]
+	quantity := minShares(shares, lot.Remaining)
+	if quantity < lot.Remaining {
+		rest := lot
+		basis := lot.Basis.MulFloat(quantity).DivFloat(lot.Remaining).RoundTo(stock.Currency)
+		rest.Quantity = roundShares(lot.Quantity - quantity)
+		rest.Remaining = roundShares(lot.Remaining - quantity)
+		rest.Basis = lot.Basis - basis
+		lot.Quantity = quantity
+		lot.Remaining = quantity
+		lot.Basis = basis
+		result.Lots = append(result.Lots[:j+1], append([]Lot{rest}, result.Lots[j+1:]...)...)
+	}
+
+	disallowed := (sale.Basis - sale.Proceeds).MulFloat(quantity).DivFloat(sale.Quantity).RoundTo(stock.Currency)
+	sale.Disallowed += disallowed
+	sale.Gain += disallowed
+	lot.Basis += disallowed
+	lot.WashSale += disallowed
+	lot.Acquired = carryHolding(sale.Acquired, sale.Date, lot.Date)
+	lot.replacement = true
+	result.Lots[j] = lot
+	return quantity
+}
+
+// carryHolding moves the start of the holding period of shares bought on date back by how long the shares sold were held.
+func carryHolding(acquired string, sold string, date string) string {
+	from, err1 := time.Parse("2006-01-02", acquired)
+	to, err2 := time.Parse("2006-01-02", sold)
+	bought, err3 := time.Parse("2006-01-02", date)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return date
+	}
+	return bought.Add(from.Sub(to)).Format("2006-01-02")
+}
+
+func addDays(date string, days int) string {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	return t.AddDate(0, 0, days).Format("2006-01-02")
+}
+
+// formDate writes a ledger date the way form 8949 does, 04/13/2018.
+func formDate(date string) string {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	return t.Format("01/02/2006")
+}
+
+// symbolSale is a lot sale with the symbol it is of.
+type symbolSale struct {
+	symbol string
+	sale   LotSale
+}
+
+// salesOf are the lot sales of year of the stocks in USD, short or long term.
+func salesOf(stocks []Stock, year string, long bool) []symbolSale {
+	sales := []symbolSale{}
+	for _, stock := range stocks {
+		if stock.Currency != "USD" {
+			continue
+		}
+		for _, sale := range stock.Lots.Sales {
+			if sale.Date[:4] == year && sale.LongTerm == long {
+				sales = append(sales, symbolSale{stock.Symbol, sale})
+			}
+		}
+	}
+	return sales
+}
+
+// GetForm8949CSV lists the lot sales of year of the stocks in USD like form 8949, short term (part I) then long
+// term (part II). A wash sale has code W and its disallowed loss as the adjustment.
+func GetForm8949CSV(stocks []Stock, year string) string {
+	str := "Part, (a) Description of property, (b) Date acquired, (c) Date sold, (d) Proceeds, (e) Cost basis, (f) Code, (g) Adjustment, (h) Gain or (loss)\n"
+	for _, part := range []string{"I", "II"} {
+		for _, s := range salesOf(stocks, year, part == "II") {
+			symbol, sale := s.symbol, s.sale
+			code := ""
+			if !sale.Disallowed.IsZero() {
+				code = "W"
+			}
+			str += fmt.Sprintf("%s, %s sh %s, %s, %s, %s, %s, %s, %s, %s\n", part, FormatShares(sale.Quantity), symbol, formDate(sale.Acquired), formDate(sale.Date),
+				sale.Proceeds.In("USD"), sale.Basis.In("USD"), code, sale.Disallowed.In("USD"), sale.Gain.In("USD"))
+		}
+	}
+	return str
+}
+
+// GetWashSalesString sums the lot sales of year of the stocks in USD by term and lists the wash sales.
+func GetWashSalesString(stocks []Stock, year string) string {
+	str := "\nUS gains " + year + "\n"
+	washes := ""
+	for _, long := range []bool{false, true} {
+		proceeds, basis, disallowed, gain := money.Zero, money.Zero, money.Zero, money.Zero
+		for _, s := range salesOf(stocks, year, long) {
+			symbol, sale := s.symbol, s.sale
+			proceeds += sale.Proceeds
+			basis += sale.Basis
+			disallowed += sale.Disallowed
+			gain += sale.Gain
+			if !sale.Disallowed.IsZero() {
+				washes += fmt.Sprintf("  %-10s sold %s  %9s from %-24s loss %12s  disallowed %12s\n", symbol, sale.Date, FormatShares(sale.Quantity),
+					sale.LotID, (sale.Proceeds - sale.Basis).In("USD"), sale.Disallowed.In("USD"))
+			}
+		}
+		term := "Short term"
+		if long {
+			term = "Long term "
+		}
+		str += fmt.Sprintf("  %s  proceeds %12s  cost %12s  wash sales %12s  gain %12s\n", term, proceeds.In("USD"), basis.In("USD"), disallowed.In("USD"), gain.In("USD"))
+	}
+	if washes != "" {
+		str += "\nWash sales (the loss is added to the basis of the shares bought back)\n" + washes
+	}
+	return str
+}
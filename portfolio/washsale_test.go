@@ -0,0 +1,71 @@
+package portfolio
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kmorin72/stock/money"
+)
+
+// lotsOf are the ID, acquisition date, remaining shares and basis of the lots.
+func lotsOf(result LotResult) []string {
+	lots := []string{}
+	for _, lot := range result.Lots {
+		lots = append(lots, fmt.Sprintf("%s %s %s %s", lot.ID, lot.Acquired, FormatShares(lot.Remaining), lot.Basis.In("USD")))
+	}
+	return lots
+}
+
+func TestWashSales(t *testing.T) {
+	tests := []struct {
+		name       string
+		buy        string
+		quantity   float64
+		washSales  bool
+		disallowed string
+		lots       []string
+	}{
+		{"not applied", "2018-06-19", 100, false, "0.00", []string{"AAPL:2018-01-10 2018-01-10 0 0.00", "AAPL:2018-06-19 2018-06-19 100 4100.00"}},
+		// held 142 days before the sale, so bought back 142 days earlier
+		{"bought back after", "2018-06-19", 100, true, "1000.00", []string{"AAPL:2018-01-10 2018-01-10 0 0.00", "AAPL:2018-06-19 2018-01-28 100 5100.00"}},
+		{"bought back before", "2018-05-20", 100, true, "1000.00", []string{"AAPL:2018-01-10 2018-01-10 0 0.00", "AAPL:2018-05-20 2017-12-29 100 5100.00"}},
+		{"bought back late", "2018-07-02", 100, true, "0.00", []string{"AAPL:2018-01-10 2018-01-10 0 0.00", "AAPL:2018-07-02 2018-07-02 100 4100.00"}},
+		{"part bought back", "2018-06-19", 40, true, "400.00", []string{"AAPL:2018-01-10 2018-01-10 0 0.00", "AAPL:2018-06-19 2018-01-28 40 2040.00"}},
+		{"lot split", "2018-06-19", 150, true, "1000.00", []string{"AAPL:2018-01-10 2018-01-10 0 0.00", "AAPL:2018-06-19 2018-01-28 100 5100.00", "AAPL:2018-06-19 2018-06-19 50 2050.00"}},
+	}
+	for _, test := range tests {
+		stock := newStock("AAPL")
+		addTx(&stock, "buy", "2018-01-10", 100, 50, 0, 0)
+		addTx(&stock, "sell", "2018-06-01", 100, 40, 0, 0)
+		addTx(&stock, "buy", test.buy, test.quantity, 41, 0, 0)
+
+		result := ComputeLots(stock, FIFO, test.washSales)
+		sale := result.Sales[0]
+		if sale.Disallowed.In("USD") != test.disallowed || sale.Gain != money.FromInt(-1000)+sale.Disallowed {
+			t.Errorf("%s: disallowed %s with a gain of %s, want %s disallowed", test.name, sale.Disallowed.In("USD"), sale.Gain.In("USD"), test.disallowed)
+		}
+		if lots := lotsOf(result); fmt.Sprint(lots) != fmt.Sprint(test.lots) {
+			t.Errorf("%s: lots are\n%v, want\n%v", test.name, lots, test.lots)
+		}
+	}
+}
+
+func TestWashSaleKeepsTheHoldingPeriod(t *testing.T) {
+	stock := newStock("AAPL")
+	addTx(&stock, "buy", "2018-01-10", 100, 50, 0, 0)
+	addTx(&stock, "sell", "2018-06-01", 100, 40, 0, 0)
+	addTx(&stock, "buy", "2018-06-19", 150, 41, 0, 0)
+	addTx(&stock, "sell", "2019-02-01", 150, 45, 0, 0)
+
+	// the 100 shares that replaced the ones sold are held since 2018-01-28, the 50 others since 2018-06-19
+	sales := ComputeLots(stock, FIFO, true).Sales[1:]
+	if len(sales) != 2 {
+		t.Fatalf("%d lot sales, want 2", len(sales))
+	}
+	if !sales[0].LongTerm || sales[0].Quantity != 100 || sales[0].Gain != money.FromInt(-600) {
+		t.Errorf("first sale is %+v, want 100 shares long term with a gain of -600.00", sales[0])
+	}
+	if sales[1].LongTerm || sales[1].Quantity != 50 || sales[1].Gain != money.FromInt(200) {
+		t.Errorf("second sale is %+v, want 50 shares short term with a gain of 200.00", sales[1])
+	}
+}
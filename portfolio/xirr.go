@@ -0,0 +1,204 @@
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/kmorin72/stock/money"
+)
+
+// CashFlow is money put into a position, negative, or taken out of it, positive, on a day.
+type CashFlow struct {
+	Date   string
+	Amount money.Amount
+}
+
+// CashFlows are the buys, sells and dividends of the timeline of the stock as the investor saw them: a buy costs
+// its shares and costs, a sell brings its proceeds less its costs and a dividend brings its payout.
+func CashFlows(stock Stock) []CashFlow {
+	flows, _ := cashFlowsIn(stock, stock.Currency, nil)
+	return flows
+}
+
+// cashFlowsIn is CashFlows converted like ProcessTimelineIn does, it fails with the first rate that is missing.
+func cashFlowsIn(stock Stock, currency string, rate func(date string) (float64, error)) ([]CashFlow, error) {
+	var err error
+	convert := func(amount money.Amount, date string) money.Amount {
+		amount = amount.RoundTo(stock.Currency)
+		if rate == nil || amount.IsZero() {
+			return amount
+		}
+		r, rateErr := rate(date)
+		if rateErr != nil && err == nil {
+			err = rateErr
+		}
+		return amount.MulFloat(r).RoundTo(currency)
+	}
+	flows := []CashFlow{}
+	shares := 0.0
+	for _, date := range TimelineDates(stock) {
+		for _, event := range stock.Timeline[date] {
+			switch event.Type {
+			case "buy":
+				flows = append(flows, CashFlow{date, -convert(event.Amount.MulFloat(event.Quantity), date) - convert(event.Costs, date)})
+				shares = roundShares(shares + event.Quantity)
+			case "sell":
+				flows = append(flows, CashFlow{date, convert(event.Amount.MulFloat(event.Quantity), date) - convert(event.Costs, date)})
+				shares = roundShares(shares - event.Quantity)
+			case "dividend":
+				if payout := convert(event.Amount.MulFloat(shares), date); !payout.IsZero() {
+					flows = append(flows, CashFlow{date, payout})
+				}
+			}
+		}
+	}
+	return flows, err
+}
+
+// positionFlows are the CashFlows of the stock with the market value of the shares held as if they were sold on now.
+// False when shares are held but there is no price to value them.
+func positionFlows(stock Stock, now time.Time) ([]CashFlow, bool) {
+	flows := append([]CashFlow{}, stock.CashFlows...)
+	if stock.TLR.NumberOfShares <= 0 {
+		return flows, true
+	}
+	if stock.FetchError != "" {
+		return flows, false
+	}
+	mv := stock.Price.MulFloat(stock.TLR.NumberOfShares).RoundTo(stock.Currency)
+	return append(flows, CashFlow{now.Format("2006-01-02"), mv}), true
+}
+
+// stockXIRR is the XIRR of the position in the stock on now, NaN without a price for the shares held.
+func stockXIRR(stock Stock, now time.Time) float64 {
+	flows, ok := positionFlows(stock, now)
+	if !ok {
+		return math.NaN()
+	}
+	return XIRR(flows)
+}
+
+// XIRR is the yearly rate that makes the flows add up to zero once discounted to the first of them, in %.
+// It is NaN when there is no such rate, the flows need money going in and coming out.
+func XIRR(flows []CashFlow) float64 {
+	if len(flows) == 0 {
+		return math.NaN()
+	}
+	first, err := time.Parse("2006-01-02", flows[0].Date)
+	if err != nil {
+		return math.NaN()
+	}
+	years := make([]float64, len(flows))
+	amounts := make([]float64, len(flows))
+	in, out := false, false
+	for i, flow := range flows {
+		date, err := time.Parse("2006-01-02", flow.Date)
+		if err != nil {
+			return math.NaN()
+		}
+		if date.Before(first) {
+			first = date
+		}
+		years[i] = float64(date.Unix())
+		amounts[i] = flow.Amount.Float64()
+		in = in || amounts[i] < 0
+		out = out || amounts[i] > 0
+	}
+	if !in || !out {
+		return math.NaN()
+	}
+	for i := range years {
+		years[i] = (years[i] - float64(first.Unix())) / (365 * 24 * 3600)
+	}
+	npv := func(rate float64) float64 {
+		sum := 0.0
+		for i := range amounts {
+			sum += amounts[i] / math.Pow(1+rate, years[i])
+		}
+		return sum
+	}
+
+	// Newton from 10%, then halving the interval when it does not settle
+	rate := 0.1
+	for i := 0; i < 50; i++ {
+		value, derivative := 0.0, 0.0
+		for j := range amounts {
+			value += amounts[j] / math.Pow(1+rate, years[j])
+			derivative -= years[j] * amounts[j] / math.Pow(1+rate, years[j]+1)
+		}
+		if math.Abs(value) < 1e-7 {
+			return rate * 100
+		}
+		if derivative == 0 {
+			break
+		}
+		next := rate - value/derivative
+		if next <= -1 || math.IsNaN(next) || math.IsInf(next, 0) {
+			break
+		}
+		if math.Abs(next-rate) < 1e-10 {
+			return next * 100
+		}
+		rate = next
+	}
+	low, high := -0.999999, 1.0
+	for npv(low)*npv(high) > 0 {
+		if high > 1e6 {
+			return math.NaN()
+		}
+		high *= 10
+	}
+	for i := 0; i < 200; i++ {
+		mid := (low + high) / 2
+		if npv(low)*npv(mid) <= 0 {
+			high = mid
+		} else {
+			low = mid
+		}
+	}
+	return (low + high) / 2 * 100
+}
+
+// XIRR is the XIRR of the stocks of each currency on Now, the stocks without a price for their shares are left out.
+func (p *Portfolio) XIRR() map[string]float64 {
+	flows := make(map[string][]CashFlow)
+	for _, symbol := range p.Symbols() {
+		stock := p.Stocks[symbol]
+		if stockFlows, ok := positionFlows(stock, p.Now); ok {
+			flows[stock.Currency] = append(flows[stock.Currency], stockFlows...)
+		}
+	}
+	rates := make(map[string]float64)
+	for currency, currencyFlows := range flows {
+		rates[currency] = XIRR(currencyFlows)
+	}
+	return rates
+}
+
+// formatRate writes a rate in % like the ROI columns, n/a when there is none.
+func formatRate(rate float64) string {
+	if math.IsNaN(rate) {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.2f%%", rate)
+}
+
+// GetXIRRString has the XIRR of the portfolio by currency.
+func (p *Portfolio) GetXIRRString() string {
+	rates := p.XIRR()
+	currencies := []string{}
+	for currency := range rates {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	str := ""
+	for _, currency := range currencies {
+		str += fmt.Sprintf("%s XIRR                     %s\n", currency, formatRate(rates[currency]))
+	}
+	if str != "" {
+		str = "\n" + str
+	}
+	return str
+}
@@ -0,0 +1,51 @@
+package portfolio
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kmorin72/stock/money"
+)
+
+// testFlows makes the cash flows of pairs of a date and an amount.
+func testFlows(pairs ...interface{}) []CashFlow {
+	flows := []CashFlow{}
+	for i := 0; i < len(pairs); i += 2 {
+		flows = append(flows, CashFlow{pairs[i].(string), money.FromFloat(pairs[i+1].(float64))})
+	}
+	return flows
+}
+
+func TestXIRR(t *testing.T) {
+	tests := []struct {
+		name  string
+		flows []CashFlow
+		want  float64
+	}{
+		{"one year", testFlows("2018-01-01", -1000.0, "2019-01-01", 1100.0), 10},
+		{"leap year", testFlows("2020-01-01", -1000.0, "2021-01-01", 1100.0), 9.971358593},
+		{"loss", testFlows("2018-01-01", -1000.0, "2019-01-01", 500.0), -50},
+		// the example of the spreadsheets' XIRR
+		{"several flows", testFlows("2008-01-01", -10000.0, "2008-03-01", 2750.0, "2008-10-30", 4250.0, "2009-02-15", 3250.0, "2009-04-01", 2750.0), 37.336253352},
+		{"not in date order", testFlows("2008-03-01", 2750.0, "2008-01-01", -10000.0, "2009-04-01", 2750.0, "2008-10-30", 4250.0, "2009-02-15", 3250.0), 37.336253352},
+		{"doubled in 30 days", testFlows("2018-01-01", -100.0, "2018-01-31", 200.0), 459660.454988},
+		{"no rate", testFlows("2018-01-01", -1000.0, "2019-01-01", 500.0, "2020-01-01", -1000.0), math.NaN()},
+		{"all in", testFlows("2018-01-01", -1000.0, "2019-01-01", -500.0), math.NaN()},
+		{"all out", testFlows("2018-01-01", 1000.0, "2019-01-01", 500.0), math.NaN()},
+		{"one day", testFlows("2018-01-01", -1000.0, "2018-01-01", 1010.0), math.NaN()},
+		{"no flows", nil, math.NaN()},
+		{"bad date", testFlows("2018-01-01", -1000.0, "next year", 1100.0), math.NaN()},
+	}
+	for _, test := range tests {
+		got := XIRR(test.flows)
+		if math.IsNaN(test.want) {
+			if !math.IsNaN(got) {
+				t.Errorf("%s: XIRR is %v, want NaN", test.name, got)
+			}
+			continue
+		}
+		if math.Abs(got-test.want) > 1e-6*math.Max(1, math.Abs(test.want)) {
+			t.Errorf("%s: XIRR is %v, want %v", test.name, got, test.want)
+		}
+	}
+}
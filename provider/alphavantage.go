@@ -0,0 +1,298 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// free tier limits of alphavantage.co
+const (
+	AlphaVantageCallsPerMinute = 5
+	AlphaVantageCallsPerDay    = 25
+)
+
+type alphaVantageGlobalQuote struct {
+	GlobalQuote struct {
+		Symbol        string `json:"01. symbol"`
+		Open          string `json:"02. open"`
+		High          string `json:"03. high"`
+		Low           string `json:"04. low"`
+		Price         string `json:"05. price"`
+		Volume        string `json:"06. volume"`
+		LatestDay     string `json:"07. latest trading day"`
+		PreviousClose string `json:"08. previous close"`
+		Change        string `json:"09. change"`
+		ChangePercent string `json:"10. change percent"`
+	} `json:"Global Quote"`
+}
+
+type alphaVantageDaily struct {
+	MetaData struct {
+		Information   string `json:"1. Information"`
+		Symbol        string `json:"2. Symbol"`
+		LastRefreshed string `json:"3. Last Refreshed"`
+	} `json:"Meta Data"`
+	TimeSeries map[string]struct {
+		Open   string `json:"1. open"`
+		High   string `json:"2. high"`
+		Low    string `json:"3. low"`
+		Close  string `json:"4. close"`
+		Volume string `json:"5. volume"`
+	} `json:"Time Series (Daily)"`
+}
+
+type alphaVantageMatch struct {
+	Symbol   string `json:"1. symbol"`
+	Name     string `json:"2. name"`
+	Region   string `json:"4. region"`
+	Currency string `json:"8. currency"`
+}
+
+type alphaVantageSearch struct {
+	BestMatches []alphaVantageMatch `json:"bestMatches"`
+}
+
+// AlphaVantage gets the quotes from the GLOBAL_QUOTE function and the history from TIME_SERIES_DAILY of alphavantage.co.
+// The name and currency come from SYMBOL_SEARCH and the 52 week high is taken from the daily history.
+// OutputSize is compact, the last 100 days, as full is only for the premium keys.
+// The search of a symbol is kept in SearchDir, a name and currency don't change and the free tier has few calls a day.
+// Limiter only counts the calls of the day, the calls per minute are spaced out by the Limited wrapper of FromConfig.
+type AlphaVantage struct {
+	Key        string
+	BaseURL    string
+	OutputSize string
+	SearchDir  string
+	// Suffixes maps the exchange suffix of our symbols to the one alphavantage uses, e.g. BCE.TO is BCE.TRT
+	Suffixes map[string]string
+	Limiter  *Limiter
+
+	mutex     sync.Mutex
+	histories map[string]History
+	searches  map[string]alphaVantageMatch
+}
+
+func NewAlphaVantage(key string, callsPerDay int) *AlphaVantage {
+	if callsPerDay <= 0 {
+		callsPerDay = AlphaVantageCallsPerDay
+	}
+	return &AlphaVantage{
+		Key:        key,
+		BaseURL:    "https://www.alphavantage.co/query",
+		OutputSize: "compact",
+		Suffixes:   map[string]string{".TO": ".TRT", ".V": ".TRV"},
+		Limiter:    NewLimiter(0, callsPerDay),
+		histories:  make(map[string]History),
+		searches:   make(map[string]alphaVantageMatch),
+	}
+}
+
+func (a *AlphaVantage) Name() string {
+	return "alphavantage"
+}
+
+func (a *AlphaVantage) Current(symbol string) (Quote, error) {
+	var globalQuote alphaVantageGlobalQuote
+	if err := a.query(symbol, url.Values{"function": {"GLOBAL_QUOTE"}, "symbol": {a.symbol(symbol)}}, &globalQuote); err != nil {
+		return Quote{}, err
+	}
+	if globalQuote.GlobalQuote.Price == "" {
+		return Quote{}, fmt.Errorf("alphavantage: %s - no current data", symbol)
+	}
+
+	quote := Quote{Symbol: symbol, Name: symbol, Currency: CurrencyFromSuffix(symbol), Date: globalQuote.GlobalQuote.LatestDay}
+	quote.Price, _ = strconv.ParseFloat(globalQuote.GlobalQuote.Price, 64)
+	quote.CloseYesterday, _ = strconv.ParseFloat(globalQuote.GlobalQuote.PreviousClose, 64)
+
+	match, err := a.search(symbol)
+	if err != nil {
+		return Quote{}, err
+	}
+	if match.Name != "" {
+		quote.Name = match.Name
+	}
+	if match.Currency != "" {
+		quote.Currency = match.Currency
+	}
+
+	history, err := a.History(symbol)
+	if err != nil {
+		return Quote{}, err
+	}
+	yearAgo := time.Now().AddDate(-1, 0, 0).Format("2006-01-02")
+	quote.FiftyTwoWeekLow = quote.Price
+	for _, day := range history.Days {
+		if day.Date < yearAgo {
+			break
+		}
+		if day.High > quote.FiftyTwoWeekHigh {
+			quote.FiftyTwoWeekHigh = day.High
+		}
+		if day.Low > 0 && day.Low < quote.FiftyTwoWeekLow {
+			quote.FiftyTwoWeekLow = day.Low
+		}
+	}
+	if quote.Price > quote.FiftyTwoWeekHigh {
+		quote.FiftyTwoWeekHigh = quote.Price
+	}
+	return quote, nil
+}
+
+// History is fetched once per symbol, Current reuses it for the 52 week high.
+func (a *AlphaVantage) History(symbol string) (History, error) {
+	a.mutex.Lock()
+	history, isIn := a.histories[symbol]
+	a.mutex.Unlock()
+	if isIn {
+		return history, nil
+	}
+
+	var daily alphaVantageDaily
+	if err := a.query(symbol, url.Values{"function": {"TIME_SERIES_DAILY"}, "symbol": {a.symbol(symbol)}, "outputsize": {a.OutputSize}}, &daily); err != nil {
+		return History{}, err
+	}
+	if len(daily.TimeSeries) == 0 {
+		return History{}, fmt.Errorf("alphavantage: %s - no history", symbol)
+	}
+
+	history = History{Symbol: symbol, Name: symbol}
+	for date, data := range daily.TimeSeries {
+		d := Day{Date: date}
+		d.Open, _ = strconv.ParseFloat(data.Open, 64)
+		d.Close, _ = strconv.ParseFloat(data.Close, 64)
+		d.High, _ = strconv.ParseFloat(data.High, 64)
+		d.Low, _ = strconv.ParseFloat(data.Low, 64)
+		d.Volume, _ = strconv.ParseFloat(data.Volume, 64)
+		history.Days = append(history.Days, d)
+	}
+	sort.Slice(history.Days, func(i, j int) bool { return history.Days[i].Date > history.Days[j].Date })
+
+	a.mutex.Lock()
+	a.histories[symbol] = history
+	a.mutex.Unlock()
+	return history, nil
+}
+
+// search is the SYMBOL_SEARCH match of the symbol, from memory or SearchDir when it was searched before.
+// No match is kept too, the quote then has the name and currency of the symbol itself.
+func (a *AlphaVantage) search(symbol string) (alphaVantageMatch, error) {
+	a.mutex.Lock()
+	match, isIn := a.searches[symbol]
+	a.mutex.Unlock()
+	if isIn {
+		return match, nil
+	}
+	file := ""
+	if a.SearchDir != "" {
+		file = filepath.Join(a.SearchDir, symbol+"-search.json")
+		if raw, err := ioutil.ReadFile(file); err == nil && json.Unmarshal(raw, &match) == nil {
+			a.remember(symbol, match)
+			return match, nil
+		}
+	}
+
+	var search alphaVantageSearch
+	if err := a.query(symbol, url.Values{"function": {"SYMBOL_SEARCH"}, "keywords": {a.symbol(symbol)}}, &search); err != nil {
+		return alphaVantageMatch{}, err
+	}
+	for _, found := range search.BestMatches {
+		if strings.EqualFold(found.Symbol, a.symbol(symbol)) {
+			match = found
+			break
+		}
+	}
+	a.remember(symbol, match)
+	if file != "" {
+		if raw, err := json.Marshal(match); err == nil {
+			if err = writeFileAtomic(file, raw, 0644); err != nil {
+				println("alphavantage: " + err.Error())
+			}
+		}
+	}
+	return match, nil
+}
+
+func (a *AlphaVantage) remember(symbol string, match alphaVantageMatch) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.searches[symbol] = match
+}
+
+func (a *AlphaVantage) symbol(symbol string) string {
+	for suffix, replacement := range a.Suffixes {
+		if strings.HasSuffix(symbol, suffix) {
+			return strings.TrimSuffix(symbol, suffix) + replacement
+		}
+	}
+	return symbol
+}
+
+// query calls the api and decodes the body into v, the notes alphavantage sends back instead of data are returned as errors.
+func (a *AlphaVantage) query(symbol string, params url.Values, v interface{}) error {
+	if a.Limiter != nil && !a.Limiter.Wait() {
+		return fmt.Errorf("alphavantage: %s - daily call limit of %d reached", symbol, a.Limiter.PerDay)
+	}
+	params.Set("apikey", a.Key)
+	response, err := http.Get(a.BaseURL + "?" + params.Encode())
+	if err != nil {
+		return Temporary(fmt.Errorf("alphavantage: %s - %s", symbol, err.Error()))
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return Temporary(fmt.Errorf("alphavantage: %s - %s", symbol, err.Error()))
+	}
+	if response.StatusCode != http.StatusOK {
+		err := fmt.Errorf("alphavantage: %s - %s", symbol, response.Status)
+		if temporaryStatus(response.StatusCode) {
+			err = Temporary(err)
+		}
+		return err
+	}
+
+	var notes map[string]interface{}
+	if err := json.Unmarshal(body, &notes); err != nil {
+		return fmt.Errorf("alphavantage: %s - %s", symbol, err.Error())
+	}
+	if message, isIn := notes["Error Message"]; isIn {
+		return fmt.Errorf("alphavantage: %s - %v", symbol, message)
+	}
+	// Note and Information are sent when a limit is reached or the call needs a premium key
+	for _, key := range []string{"Note", "Information"} {
+		if note, isIn := notes[key]; isIn {
+			err := fmt.Errorf("alphavantage: %s - %v", symbol, note)
+			switch noteKind(fmt.Sprint(note)) {
+			case "day":
+				if a.Limiter != nil {
+					a.Limiter.UseUp()
+				}
+				return err
+			case "premium":
+				return err
+			}
+			return Temporary(err)
+		}
+	}
+	return json.Unmarshal(body, v)
+}
+
+// noteKind tells why alphavantage sent a note: "premium" for an option of the premium keys and "day" for the daily
+// limit, trying again does not help with either. The other notes are about the calls per minute.
+func noteKind(note string) string {
+	note = strings.ToLower(note)
+	switch {
+	case strings.Contains(note, "premium") && !strings.Contains(note, "per day"):
+		return "premium"
+	case strings.Contains(note, "per day") && !strings.Contains(note, "per minute"):
+		return "day"
+	}
+	return "minute"
+}
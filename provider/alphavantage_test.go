@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kmorin72/stock/utils"
+)
+
+// alphaVantageStandIn serves the recorded responses of testdata/alphavantage by function, or the one of respond
+// when it is set, and counts the calls of each function.
+type alphaVantageStandIn struct {
+	t       *testing.T
+	respond string
+
+	mutex sync.Mutex
+	calls map[string]int
+	query map[string]string
+}
+
+func (s *alphaVantageStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	function := r.URL.Query().Get("function")
+	s.mutex.Lock()
+	s.calls[function]++
+	s.query[function] = r.URL.RawQuery
+	s.mutex.Unlock()
+
+	file := function + ".json"
+	if s.respond != "" {
+		file = s.respond
+	}
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "alphavantage", file))
+	if err != nil {
+		s.t.Errorf("no recorded response for %s: %s", function, err)
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func newStandIn(t *testing.T, respond string) (*alphaVantageStandIn, *httptest.Server) {
+	standIn := &alphaVantageStandIn{t: t, respond: respond, calls: make(map[string]int), query: make(map[string]string)}
+	return standIn, httptest.NewServer(standIn)
+}
+
+func newTestAlphaVantage(url string, searchDir string) *AlphaVantage {
+	a := NewAlphaVantage("DEMOKEY", 0)
+	a.BaseURL = url
+	a.SearchDir = searchDir
+	return a
+}
+
+func TestAlphaVantageCurrent(t *testing.T) {
+	standIn, server := newStandIn(t, "")
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "alphavantage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	quote, err := newTestAlphaVantage(server.URL, dir).Current("BCE.TO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Name != "BCE Inc" || quote.Currency != "CAD" {
+		t.Errorf("name and currency are %q %q, want the ones of the BCE.TRT match", quote.Name, quote.Currency)
+	}
+	if quote.Price != 55.43 || quote.CloseYesterday != 55.05 || quote.Date != "2019-12-20" {
+		t.Errorf("quote is %v %v %v", quote.Price, quote.CloseYesterday, quote.Date)
+	}
+	if !strings.Contains(standIn.query["GLOBAL_QUOTE"], "symbol=BCE.TRT") {
+		t.Errorf("the suffix is not mapped: %s", standIn.query["GLOBAL_QUOTE"])
+	}
+	if !strings.Contains(standIn.query["TIME_SERIES_DAILY"], "outputsize=compact") {
+		t.Errorf("the history is not compact: %s", standIn.query["TIME_SERIES_DAILY"])
+	}
+
+	// a new provider, like the next run, finds the search in SearchDir
+	if _, err := newTestAlphaVantage(server.URL, dir).Current("BCE.TO"); err != nil {
+		t.Fatal(err)
+	}
+	if standIn.calls["SYMBOL_SEARCH"] != 1 {
+		t.Errorf("%d symbol searches, want 1", standIn.calls["SYMBOL_SEARCH"])
+	}
+	if standIn.calls["GLOBAL_QUOTE"] != 2 {
+		t.Errorf("%d quotes, want 2", standIn.calls["GLOBAL_QUOTE"])
+	}
+}
+
+func TestAlphaVantageHistory(t *testing.T) {
+	standIn, server := newStandIn(t, "")
+	defer server.Close()
+	a := newTestAlphaVantage(server.URL, "")
+
+	history, err := a.History("BCE.TO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Days) != 3 {
+		t.Fatalf("%d days, want 3", len(history.Days))
+	}
+	// most recent first
+	if history.Days[0].Date != "2019-12-20" || history.Days[2].Date != "2019-08-12" {
+		t.Errorf("days are not in order: %s ... %s", history.Days[0].Date, history.Days[2].Date)
+	}
+	if day := history.Days[1]; day.Open != 54.8 || day.High != 55.2 || day.Low != 54.71 || day.Close != 55.05 || day.Volume != 1211456 {
+		t.Errorf("day is %+v", day)
+	}
+	if _, err := a.History("BCE.TO"); err != nil {
+		t.Fatal(err)
+	}
+	if standIn.calls["TIME_SERIES_DAILY"] != 1 {
+		t.Errorf("%d history calls, want 1", standIn.calls["TIME_SERIES_DAILY"])
+	}
+}
+
+func TestAlphaVantageNotes(t *testing.T) {
+	tests := []struct {
+		respond   string
+		temporary bool
+		usedUp    bool
+	}{
+		{"minute_limit.json", true, false},
+		{"premium.json", false, false},
+		{"daily_limit.json", false, true},
+	}
+	for _, test := range tests {
+		standIn, server := newStandIn(t, test.respond)
+		a := newTestAlphaVantage(server.URL, "")
+
+		_, err := a.History("BCE.TO")
+		if err == nil {
+			t.Errorf("%s: no error", test.respond)
+		} else if IsTemporary(err) != test.temporary {
+			t.Errorf("%s: temporary is %v, want %v", test.respond, IsTemporary(err), test.temporary)
+		}
+		_, err = a.History("BCE.TO")
+		if err == nil {
+			t.Errorf("%s: no error on the second call", test.respond)
+		}
+		calls := standIn.calls["TIME_SERIES_DAILY"]
+		if test.usedUp && calls != 1 {
+			t.Errorf("%s: %d calls after the daily limit, want 1", test.respond, calls)
+		}
+		if !test.usedUp && calls != 2 {
+			t.Errorf("%s: %d calls, want 2", test.respond, calls)
+		}
+		server.Close()
+	}
+}
+
+func TestAlphaVantageLimitedOnce(t *testing.T) {
+	tests := []struct {
+		config    utils.Config
+		perMinute int
+	}{
+		{utils.Config{Provider: "alphavantage"}, AlphaVantageCallsPerMinute},
+		{utils.Config{Provider: "alphavantage", AlphaVantageCallsPerMinute: 30}, 30},
+		{utils.Config{Provider: "alphavantage", AlphaVantageCallsPerMinute: 30, RequestsPerMinute: map[string]int{"alphavantage": 75}}, 75},
+	}
+	for _, test := range tests {
+		source, err := FromConfig(test.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		limited, isLimited := source.(*Cache).Provider.(*Retrying).Provider.(*Limited)
+		if !isLimited {
+			t.Fatalf("alphavantage is not behind Limited")
+		}
+		if limited.Limiter.PerMinute != test.perMinute {
+			t.Errorf("%d calls a minute, want %d", limited.Limiter.PerMinute, test.perMinute)
+		}
+		// the calls of the day are counted, not spaced out a second time
+		if a := limited.Provider.(*AlphaVantage); a.Limiter.PerMinute != 0 || a.Limiter.PerDay != AlphaVantageCallsPerDay {
+			t.Errorf("alphavantage limits itself to %d a minute and %d a day, want 0 and %d", a.Limiter.PerMinute, a.Limiter.PerDay, AlphaVantageCallsPerDay)
+		}
+	}
+}
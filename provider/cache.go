@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultQuoteTTL   = 15 * time.Minute
+	DefaultHistoryTTL = 24 * time.Hour
+)
+
+// Cache keeps what a provider returns in <Dir>/<provider>/<symbol>-quote.json and <symbol>-history.json
+// and only goes back to the provider once the entry is older than its TTL, or when the symbol is forced to refresh.
+// Only data that passes Check is cached, and when the provider fails the cached entry is used even if it is stale.
+// Seed, when set, gives the first entry of a symbol that is not cached yet, the TTL then decides if it is used.
+type Cache struct {
+	Provider   Provider
+	Dir        string
+	QuoteTTL   time.Duration
+	HistoryTTL time.Duration
+	Seed       Seeder
+
+	mutex   sync.Mutex
+	refresh map[string]bool
+}
+
+func NewCache(source Provider, dir string, quoteTTL time.Duration, historyTTL time.Duration) *Cache {
+	if dir == "" {
+		dir = "data/cache"
+	}
+	return &Cache{source, filepath.Join(dir, source.Name()), quoteTTL, historyTTL, nil, sync.Mutex{}, make(map[string]bool)}
+}
+
+// Seeder has data of its own for the symbols, like files kept from an earlier version, with the time it was fetched.
+type Seeder interface {
+	SeedCurrent(symbol string) (Quote, bool)
+	SeedHistory(symbol string) (History, bool)
+}
+
+func (c *Cache) Name() string {
+	return c.Provider.Name()
+}
+
+// ForceRefresh makes the next Current and History calls for the symbol skip the cache.
+func (c *Cache) ForceRefresh(symbol string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.refresh[symbol+"-quote"] = true
+	c.refresh[symbol+"-history"] = true
+}
+
+func (c *Cache) Current(symbol string) (Quote, error) {
+	var cached Quote
+	file := c.file(symbol, "quote")
+	isCached := c.read(file, &cached) && cached.Check() == nil
+	if !isCached && c.Seed != nil {
+		if seeded, ok := c.Seed.SeedCurrent(symbol); ok && seeded.Check() == nil {
+			cached, isCached = seeded, true
+			c.write(file, seeded)
+		}
+	}
+	if !c.forced(symbol, "quote") && isCached && time.Since(cached.Fetched) < c.QuoteTTL {
+		return cached, nil
+	}
+	quote, err := c.Provider.Current(symbol)
+	if err == nil {
+		err = quote.Check()
+	}
+	if err != nil {
+		if isCached {
+			println("cache: " + err.Error() + ", using the quote from " + cached.Fetched.Format("2006-01-02 15:04"))
+			return cached, nil
+		}
+		return Quote{}, err
+	}
+	if quote.Fetched.IsZero() {
+		quote.Fetched = time.Now()
+	}
+	c.write(file, quote)
+	return quote, nil
+}
+
+func (c *Cache) History(symbol string) (History, error) {
+	var cached History
+	file := c.file(symbol, "history")
+	isCached := c.read(file, &cached) && cached.Check() == nil
+	if !isCached && c.Seed != nil {
+		if seeded, ok := c.Seed.SeedHistory(symbol); ok && seeded.Check() == nil {
+			cached, isCached = seeded, true
+			c.write(file, seeded)
+		}
+	}
+	if !c.forced(symbol, "history") && isCached && time.Since(cached.Fetched) < c.HistoryTTL {
+		return cached, nil
+	}
+	history, err := c.Provider.History(symbol)
+	if err == nil {
+		err = history.Check()
+	}
+	if err != nil {
+		if isCached {
+			println("cache: " + err.Error() + ", using the history from " + cached.Fetched.Format("2006-01-02 15:04"))
+			return cached, nil
+		}
+		return History{}, err
+	}
+	if history.Fetched.IsZero() {
+		history.Fetched = time.Now()
+	}
+	c.write(file, history)
+	return history, nil
+}
+
+func (c *Cache) file(symbol string, kind string) string {
+	return filepath.Join(c.Dir, symbol+"-"+kind+".json")
+}
+
+// forced reports if the entry has to be refreshed, only once per ForceRefresh.
+func (c *Cache) forced(symbol string, kind string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	key := symbol + "-" + kind
+	if c.refresh[key] {
+		delete(c.refresh, key)
+		return true
+	}
+	return false
+}
+
+func (c *Cache) read(file string, v interface{}) bool {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(raw, v) == nil
+}
+
+// write is best effort, a cache that can't be written only means we'll ask the provider again next time.
+func (c *Cache) write(file string, v interface{}) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	if err = writeFileAtomic(file, raw, 0644); err != nil {
+		println("cache: " + err.Error())
+	}
+}
@@ -0,0 +1,184 @@
+package provider
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSource quotes every symbol at price, 0 for an invalid quote, and counts the calls by symbol, the history calls
+// under "<symbol> history". The calls fail with errs first, one each, a nil one succeeds.
+type testSource struct {
+	price float64
+	errs  []error
+
+	mutex sync.Mutex
+	calls map[string]int
+}
+
+func newTestSource(price float64, errs ...error) *testSource {
+	return &testSource{price: price, errs: errs, calls: make(map[string]int)}
+}
+
+func (s *testSource) Name() string {
+	return "test"
+}
+
+func (s *testSource) call(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.calls[key]++
+	if len(s.errs) == 0 {
+		return nil
+	}
+	err := s.errs[0]
+	s.errs = s.errs[1:]
+	return err
+}
+
+func (s *testSource) Current(symbol string) (Quote, error) {
+	if err := s.call(symbol); err != nil {
+		return Quote{}, err
+	}
+	return Quote{Symbol: symbol, Name: symbol, Currency: "USD", Price: s.price}, nil
+}
+
+func (s *testSource) History(symbol string) (History, error) {
+	if err := s.call(symbol + " history"); err != nil {
+		return History{}, err
+	}
+	return History{Symbol: symbol, Days: []Day{{Date: "2019-12-20", Close: s.price}}}, nil
+}
+
+// testSeeder seeds the quote and history of AAPL, fetched at fetched.
+type testSeeder struct {
+	fetched time.Time
+}
+
+func (s testSeeder) SeedCurrent(symbol string) (Quote, bool) {
+	return Quote{Symbol: symbol, Price: 42, Fetched: s.fetched}, symbol == "AAPL"
+}
+
+func (s testSeeder) SeedHistory(symbol string) (History, bool) {
+	return History{Symbol: symbol, Days: []Day{{Date: "2019-12-19", Close: 42}}, Fetched: s.fetched}, symbol == "AAPL"
+}
+
+func newTestCache(t *testing.T, source Provider) (*Cache, func()) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewCache(source, dir, time.Hour, time.Hour), func() { os.RemoveAll(dir) }
+}
+
+func TestCacheTTL(t *testing.T) {
+	source := newTestSource(10)
+	c, remove := newTestCache(t, source)
+	defer remove()
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Current("AAPL"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.History("AAPL"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if source.calls["AAPL"] != 1 || source.calls["AAPL history"] != 1 {
+		t.Errorf("calls are %v, want one of each", source.calls)
+	}
+
+	// older than the TTL
+	c.write(c.file("AAPL", "quote"), Quote{Symbol: "AAPL", Price: 9, Fetched: time.Now().Add(-2 * time.Hour)})
+	quote, err := c.Current("AAPL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Price != 10 || source.calls["AAPL"] != 2 {
+		t.Errorf("quote is %v after %d calls, want 10 after 2", quote.Price, source.calls["AAPL"])
+	}
+
+	c.ForceRefresh("AAPL")
+	c.Current("AAPL")
+	c.History("AAPL")
+	c.Current("AAPL")
+	if source.calls["AAPL"] != 3 || source.calls["AAPL history"] != 2 {
+		t.Errorf("calls are %v, want one more of each after ForceRefresh", source.calls)
+	}
+}
+
+func TestCacheStale(t *testing.T) {
+	source := newTestSource(10, errors.New("down"), errors.New("down"))
+	c, remove := newTestCache(t, source)
+	defer remove()
+	c.QuoteTTL = 0
+
+	// nothing cached yet
+	if _, err := c.Current("AAPL"); err == nil {
+		t.Fatal("no error")
+	}
+	c.write(c.file("AAPL", "quote"), Quote{Symbol: "AAPL", Price: 9, Fetched: time.Now().Add(-2 * time.Hour)})
+	quote, err := c.Current("AAPL")
+	if err != nil || quote.Price != 9 {
+		t.Errorf("quote is %v %v, want the stale 9", quote.Price, err)
+	}
+	quote, err = c.Current("AAPL")
+	if err != nil || quote.Price != 10 {
+		t.Errorf("quote is %v %v, want 10 once the source is back", quote.Price, err)
+	}
+}
+
+func TestCacheInvalid(t *testing.T) {
+	source := newTestSource(0)
+	c, remove := newTestCache(t, source)
+	defer remove()
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Current("AAPL"); err == nil {
+			t.Error("no error for a quote without a price")
+		}
+	}
+	if source.calls["AAPL"] != 2 {
+		t.Errorf("%d calls, want 2 as the invalid quote is not cached", source.calls["AAPL"])
+	}
+}
+
+func TestCacheSeed(t *testing.T) {
+	tests := []struct {
+		name    string
+		fetched time.Time
+		price   float64
+		calls   int
+	}{
+		{"fresh", time.Now().Add(-time.Minute), 42, 0},
+		{"stale", time.Now().Add(-2 * time.Hour), 10, 1},
+	}
+	for _, test := range tests {
+		source := newTestSource(10)
+		c, remove := newTestCache(t, source)
+		c.Seed = testSeeder{test.fetched}
+
+		quote, err := c.Current("AAPL")
+		if err != nil {
+			t.Fatal(err)
+		}
+		history, err := c.History("AAPL")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if quote.Price != test.price || history.Days[0].Close != test.price {
+			t.Errorf("%s: prices are %v and %v, want %v", test.name, quote.Price, history.Days[0].Close, test.price)
+		}
+		if source.calls["AAPL"] != test.calls || source.calls["AAPL history"] != test.calls {
+			t.Errorf("%s: calls are %v, want %d of each", test.name, source.calls, test.calls)
+		}
+		// a symbol the seeder does not have
+		if _, err := c.Current("MSFT"); err != nil || source.calls["MSFT"] != 1 {
+			t.Errorf("%s: MSFT is not taken from the source: %v", test.name, err)
+		}
+		remove()
+	}
+}
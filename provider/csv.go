@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSV reads daily OHLCV files, as exported by Yahoo, Stooq or a broker, from Dir.
+// The file of BCE.TO is BCE.TO.csv or BCE_TO.csv, the columns are found by their header (Date, Open, High, Low, Close, Volume),
+// the current price is the close of the latest row and the 52 week high is taken from the year before that row.
+type CSV struct {
+	Dir string
+}
+
+func NewCSV(dir string) *CSV {
+	if dir == "" {
+		dir = "data/csv"
+	}
+	return &CSV{dir}
+}
+
+func (c *CSV) Name() string {
+	return "csv"
+}
+
+func (c *CSV) Current(symbol string) (Quote, error) {
+	history, err := c.History(symbol)
+	if err != nil {
+		return Quote{}, err
+	}
+	latest := history.Days[0]
+	quote := Quote{Symbol: symbol, Name: symbol, Currency: CurrencyFromSuffix(symbol), Date: latest.Date, Price: latest.Close, Fetched: history.Fetched}
+	if len(history.Days) > 1 {
+		quote.CloseYesterday = history.Days[1].Close
+	}
+
+	t, _ := time.Parse("2006-01-02", latest.Date)
+	yearAgo := t.AddDate(-1, 0, 0).Format("2006-01-02")
+	quote.FiftyTwoWeekLow = latest.Close
+	for _, day := range history.Days {
+		if day.Date < yearAgo {
+			break
+		}
+		high := day.High
+		if high == 0 {
+			high = day.Close
+		}
+		if high > quote.FiftyTwoWeekHigh {
+			quote.FiftyTwoWeekHigh = high
+		}
+		low := day.Low
+		if low == 0 {
+			low = day.Close
+		}
+		if low < quote.FiftyTwoWeekLow {
+			quote.FiftyTwoWeekLow = low
+		}
+	}
+	return quote, nil
+}
+
+func (c *CSV) History(symbol string) (History, error) {
+	file, err := c.open(symbol)
+	if err != nil {
+		return History{}, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return History{}, fmt.Errorf("csv: %s - %s", symbol, err.Error())
+	}
+	if len(records) < 2 {
+		return History{}, fmt.Errorf("csv: %s - no rows in %s", symbol, file.Name())
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	dateColumn, isIn := columns["date"]
+	if !isIn {
+		return History{}, fmt.Errorf("csv: %s - no Date column in %s", symbol, file.Name())
+	}
+	if _, isIn := columns["close"]; !isIn {
+		return History{}, fmt.Errorf("csv: %s - no Close column in %s", symbol, file.Name())
+	}
+	value := func(record []string, name string) float64 {
+		i, isIn := columns[name]
+		if !isIn || i >= len(record) {
+			return 0
+		}
+		v, _ := strconv.ParseFloat(strings.TrimSpace(record[i]), 64)
+		return v
+	}
+
+	history := History{Symbol: symbol, Name: symbol}
+	if info, err := file.Stat(); err == nil {
+		history.Fetched = info.ModTime()
+	}
+	for _, record := range records[1:] {
+		if dateColumn >= len(record) {
+			continue
+		}
+		date, err := parseCSVDate(record[dateColumn])
+		if err != nil {
+			continue
+		}
+		day := Day{date, value(record, "open"), value(record, "close"), value(record, "high"), value(record, "low"), value(record, "volume")}
+		// yahoo writes null for the days it has no price
+		if day.Close == 0 {
+			continue
+		}
+		history.Days = append(history.Days, day)
+	}
+	if len(history.Days) == 0 {
+		return History{}, fmt.Errorf("csv: %s - no prices in %s", symbol, file.Name())
+	}
+	sort.Slice(history.Days, func(i, j int) bool { return history.Days[i].Date > history.Days[j].Date })
+	return history, nil
+}
+
+func (c *CSV) open(symbol string) (*os.File, error) {
+	for _, name := range []string{symbol, strings.Replace(symbol, ".", "_", -1)} {
+		file, err := os.Open(filepath.Join(c.Dir, name+".csv"))
+		if err == nil {
+			return file, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("csv: %s - %s", symbol, err.Error())
+		}
+	}
+	return nil, fmt.Errorf("csv: %s - no file %s.csv in %s", symbol, symbol, c.Dir)
+}
+
+func parseCSVDate(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{"2006-01-02", "20060102", "2006/01/02", "01/02/2006"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("2006-01-02"), nil
+		}
+	}
+	return "", fmt.Errorf("csv: unknown date format %q", value)
+}
@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCSVHistory(t *testing.T) {
+	c := NewCSV(filepath.Join("testdata", "csv"))
+
+	history, err := c.History("BCE.TO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// most recent first, the null row left out
+	dates := []string{}
+	for _, day := range history.Days {
+		dates = append(dates, day.Date)
+	}
+	if len(dates) != 4 || dates[0] != "2019-12-20" || dates[1] != "2019-12-18" || dates[3] != "2018-12-20" {
+		t.Errorf("dates are %v", dates)
+	}
+	if day := history.Days[0]; day != (Day{"2019-12-20", 55.1, 55.43, 56, 54.95, 1500}) {
+		t.Errorf("day is %+v", day)
+	}
+
+	// another column order and date format
+	history, err = c.History("AAPL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Days) != 2 || history.Days[0] != (Day{Date: "2019-12-20", Close: 150.5}) {
+		t.Errorf("days are %+v", history.Days)
+	}
+}
+
+func TestCSVCurrent(t *testing.T) {
+	c := NewCSV(filepath.Join("testdata", "csv"))
+	tests := []struct {
+		symbol string
+		want   Quote
+	}{
+		{"BCE.TO", Quote{Symbol: "BCE.TO", Name: "BCE.TO", Currency: "CAD", Date: "2019-12-20", Price: 55.43, CloseYesterday: 55.2, FiftyTwoWeekHigh: 56, FiftyTwoWeekLow: 51.1}},
+		// without High and Low columns the closes are used
+		{"AAPL", Quote{Symbol: "AAPL", Name: "AAPL", Currency: "USD", Date: "2019-12-20", Price: 150.5, CloseYesterday: 149.25, FiftyTwoWeekHigh: 150.5, FiftyTwoWeekLow: 149.25}},
+	}
+	for _, test := range tests {
+		quote, err := c.Current(test.symbol)
+		if err != nil {
+			t.Fatal(err)
+		}
+		quote.Fetched = test.want.Fetched
+		if quote != test.want {
+			t.Errorf("%s quote is %+v, want %+v", test.symbol, quote, test.want)
+		}
+	}
+}
+
+func TestCSVErrors(t *testing.T) {
+	c := NewCSV(filepath.Join("testdata", "csv"))
+	for _, symbol := range []string{"NOFILE", "NOCLOSE", "EMPTY"} {
+		if _, err := c.History(symbol); err == nil {
+			t.Errorf("%s: no error", symbol)
+		}
+		if _, err := c.Current(symbol); err == nil {
+			t.Errorf("%s: no error for the quote", symbol)
+		}
+	}
+}
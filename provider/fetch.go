@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"sync"
+)
+
+// DefaultWorkers is how many symbols are fetched at the same time when the config does not say.
+const DefaultWorkers = 4
+
+// Result is what FetchAll got for one symbol, Err is set when either the quote or the history failed.
+type Result struct {
+	Symbol  string
+	Quote   Quote
+	History History
+	Err     error
+}
+
+// FetchAll gets the quote and history of every symbol with at most workers running at once.
+// The results are in the same order as symbols, whatever order they completed in.
+func FetchAll(source Provider, symbols []string, workers int) []Result {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	results := make([]Result, len(symbols))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = fetch(source, symbols[i])
+			}
+		}()
+	}
+	for i := range symbols {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+func fetch(source Provider, symbol string) Result {
+	result := Result{Symbol: symbol}
+	result.Quote, result.Err = source.Current(symbol)
+	if result.Err != nil {
+		return result
+	}
+	result.History, result.Err = source.History(symbol)
+	return result
+}
+
+// Limited makes every call to Provider wait on Limiter, so concurrent fetches stay within the provider quota.
+type Limited struct {
+	Provider Provider
+	Limiter  *Limiter
+}
+
+func NewLimited(source Provider, perMinute int) *Limited {
+	return &Limited{source, NewLimiter(perMinute, 0)}
+}
+
+func (l *Limited) Name() string {
+	return l.Provider.Name()
+}
+
+func (l *Limited) Current(symbol string) (Quote, error) {
+	l.Limiter.Wait()
+	return l.Provider.Current(symbol)
+}
+
+func (l *Limited) History(symbol string) (History, error) {
+	l.Limiter.Wait()
+	return l.Provider.History(symbol)
+}
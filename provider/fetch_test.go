@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowSource takes a while on each call and keeps the most calls it had running at once, it fails on "BAD".
+type slowSource struct {
+	mutex   sync.Mutex
+	running int
+	most    int
+}
+
+func (s *slowSource) Name() string {
+	return "slow"
+}
+
+func (s *slowSource) call(symbol string) error {
+	s.mutex.Lock()
+	s.running++
+	if s.running > s.most {
+		s.most = s.running
+	}
+	s.mutex.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	s.mutex.Lock()
+	s.running--
+	s.mutex.Unlock()
+	if symbol == "BAD" {
+		return errors.New("slow: BAD - unknown symbol")
+	}
+	return nil
+}
+
+func (s *slowSource) Current(symbol string) (Quote, error) {
+	return Quote{Symbol: symbol, Price: 1}, s.call(symbol)
+}
+
+func (s *slowSource) History(symbol string) (History, error) {
+	return History{Symbol: symbol, Days: []Day{{Date: "2019-12-20", Close: 1}}}, s.call(symbol)
+}
+
+func TestFetchAll(t *testing.T) {
+	symbols := []string{"A", "B", "BAD", "C", "D", "E", "F", "G"}
+	source := &slowSource{}
+
+	results := FetchAll(source, symbols, 3)
+	if len(results) != len(symbols) {
+		t.Fatalf("%d results, want %d", len(results), len(symbols))
+	}
+	for i, result := range results {
+		if result.Symbol != symbols[i] || result.Quote.Symbol != symbols[i] {
+			t.Errorf("result %d is of %s, want %s", i, result.Symbol, symbols[i])
+		}
+		if (result.Err != nil) != (symbols[i] == "BAD") {
+			t.Errorf("%s: error %v", result.Symbol, result.Err)
+		}
+		if result.Err == nil && len(result.History.Days) != 1 {
+			t.Errorf("%s: no history", result.Symbol)
+		}
+	}
+	if source.most > 3 || source.most < 2 {
+		t.Errorf("%d calls at once, want up to 3", source.most)
+	}
+
+	// the default number of workers
+	source = &slowSource{}
+	FetchAll(source, symbols, 0)
+	if source.most > DefaultWorkers {
+		t.Errorf("%d calls at once, want up to %d", source.most, DefaultWorkers)
+	}
+}
+
+func TestLimited(t *testing.T) {
+	source := newTestSource(10)
+	// one call every 50ms
+	limited := NewLimited(source, 1200)
+
+	start := time.Now()
+	results := FetchAll(limited, []string{"A", "B"}, 2)
+	elapsed := time.Since(start)
+	for _, result := range results {
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+	}
+	// 4 calls, the first one right away
+	if elapsed < 150*time.Millisecond {
+		t.Errorf("4 calls took %v, want at least 150ms", elapsed)
+	}
+	if limited.Name() != "test" {
+		t.Errorf("name is %s, want the one of the provider", limited.Name())
+	}
+}
@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "test", "AAPL-quote.json")
+
+	// the directory is made, then the file is replaced
+	for _, data := range []string{"first", "second"} {
+		if err := writeFileAtomic(file, []byte(data), 0640); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadFile(file)
+		if err != nil || string(got) != data {
+			t.Errorf("file has %q %v, want %q", got, err, data)
+		}
+	}
+	info, err := os.Stat(file)
+	if err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("file mode is %v %v, want 0640", info.Mode(), err)
+	}
+
+	// a write that fails leaves what was there
+	blocked := filepath.Join(dir, "test", "blocked")
+	if err := os.Mkdir(blocked, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(blocked, []byte("third"), 0644); err == nil {
+		t.Error("no error writing over a directory")
+	}
+	if info, err := os.Stat(blocked); err != nil || !info.IsDir() {
+		t.Errorf("the directory is gone: %v", err)
+	}
+
+	// no temporary file is left behind
+	files, err := ioutil.ReadDir(filepath.Join(dir, "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		names := []string{}
+		for _, f := range files {
+			names = append(names, f.Name())
+		}
+		t.Errorf("files are %v, want AAPL-quote.json and blocked", names)
+	}
+}
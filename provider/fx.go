@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kmorin72/stock/utils"
+)
+
+// FX rates are histories like the prices: the history of USDCAD has the CAD paid for one USD as the close of each day.
+
+// FXPair is the symbol of the rates from one currency to another, USDCAD.
+func FXPair(from string, to string) string {
+	return from + to
+}
+
+// FXFromConfig returns the source of the exchange rates selected by the "fxProvider" entry of the config, csv when not set,
+// behind the cache of the config like the prices. The rates are kept for historyTTL.
+func FXFromConfig(config utils.Config) (Provider, error) {
+	dir := config.FxDirectory
+	if dir == "" {
+		dir = "data/fx"
+	}
+	var source Provider
+	switch strings.ToLower(config.FxProvider) {
+	case "", "csv":
+		source = NewCSV(dir)
+	case "valet", "bankofcanada":
+		source = NewValet(config.FxBaseURL, dir)
+	default:
+		return nil, fmt.Errorf("provider: unknown fxProvider %q", config.FxProvider)
+	}
+
+	retries := DefaultRetries
+	if config.Retries != nil {
+		retries = *config.Retries
+	}
+	retryDelay, err := parseTTL(config.RetryDelay, DefaultRetryDelay)
+	if err != nil {
+		return nil, fmt.Errorf("provider: retryDelay - %s", err.Error())
+	}
+	historyTTL, err := parseTTL(config.HistoryTTL, DefaultHistoryTTL)
+	if err != nil {
+		return nil, fmt.Errorf("provider: historyTTL - %s", err.Error())
+	}
+	return NewCache(NewRetrying(source, retries, retryDelay), config.CacheDirectory, historyTTL, historyTTL), nil
+}
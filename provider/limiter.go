@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter spaces out calls so that no more than PerMinute are made in a minute, and refuses calls past PerDay.
+// A zero value for either limit means no limit.
+type Limiter struct {
+	PerMinute int
+	PerDay    int
+
+	mutex sync.Mutex
+	last  time.Time
+	day   string
+	calls int
+	spent bool
+}
+
+func NewLimiter(perMinute int, perDay int) *Limiter {
+	return &Limiter{PerMinute: perMinute, PerDay: perDay}
+}
+
+// Wait blocks until the next call is allowed, returns false if the daily limit is used up.
+func (l *Limiter) Wait() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	today := time.Now().Format("2006-01-02")
+	if today != l.day {
+		l.day = today
+		l.calls = 0
+		l.spent = false
+	}
+	if l.spent || l.PerDay > 0 && l.calls >= l.PerDay {
+		return false
+	}
+	if l.PerMinute > 0 && !l.last.IsZero() {
+		interval := time.Minute / time.Duration(l.PerMinute)
+		if wait := interval - time.Since(l.last); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+	l.last = time.Now()
+	l.calls++
+	return true
+}
+
+// UseUp refuses the calls until the end of the day, when the source says the daily limit is reached before we counted it.
+func (l *Limiter) UseUp() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.day = time.Now().Format("2006-01-02")
+	l.spent = true
+}
@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kmorin72/stock/utils"
+)
+
+// Quote is the provider neutral view of the current trading data of a symbol.
+type Quote struct {
+	Symbol           string    `json:"symbol"`
+	Name             string    `json:"name"`
+	Currency         string    `json:"currency"`
+	Date             string    `json:"date,omitempty"` // day of the price, empty when it is live
+	Price            float64   `json:"price"`
+	FiftyTwoWeekHigh float64   `json:"52_week_high"`
+	FiftyTwoWeekLow  float64   `json:"52_week_low"`
+	CloseYesterday   float64   `json:"close_yesterday"`
+	Fetched          time.Time `json:"fetched"` // when the data was taken from the source
+}
+
+// Day is one daily bar of a price history.
+type Day struct {
+	Date   string  `json:"date"` // 2006-01-02
+	Open   float64 `json:"open"`
+	Close  float64 `json:"close"`
+	High   float64 `json:"high"`
+	Low    float64 `json:"low"`
+	Volume float64 `json:"volume"`
+}
+
+// History is the daily price history of a symbol, most recent day first.
+type History struct {
+	Symbol  string    `json:"symbol"`
+	Name    string    `json:"name"`
+	Days    []Day     `json:"days"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// Check returns why the quote can't be used, nil when it can.
+func (q Quote) Check() error {
+	if q.Price <= 0 {
+		return fmt.Errorf("%s - invalid price %.4f", q.Symbol, q.Price)
+	}
+	return nil
+}
+
+// Check returns why the history can't be used, nil when it can.
+func (h History) Check() error {
+	if len(h.Days) == 0 {
+		return fmt.Errorf("%s - no history", h.Symbol)
+	}
+	for _, day := range h.Days {
+		if _, err := time.Parse("2006-01-02", day.Date); err != nil {
+			return fmt.Errorf("%s - invalid date %q in history", h.Symbol, day.Date)
+		}
+	}
+	return nil
+}
+
+// Provider is implemented by every source of market data the report can use.
+type Provider interface {
+	Name() string
+	Current(symbol string) (Quote, error)
+	History(symbol string) (History, error)
+}
+
+// FromConfig returns the provider selected by the "provider" entry of the config, wtd when not set,
+// behind the cache of the config.
+func FromConfig(config utils.Config) (Provider, error) {
+	var source Provider
+	var seed Seeder
+	switch strings.ToLower(config.Provider) {
+	case "", "wtd", "worldtradingdata":
+		wtd := NewWorldTradingData(config.WtdToken, config.UseLocalFiles)
+		source, seed = wtd, wtd
+	case "csv":
+		source = NewCSV(config.CsvDirectory)
+	case "alphavantage":
+		alphaVantage := NewAlphaVantage(config.AlphaVantageKey, config.AlphaVantageCallsPerDay)
+		if config.AlphaVantageBaseURL != "" {
+			alphaVantage.BaseURL = config.AlphaVantageBaseURL
+		}
+		if config.AlphaVantageOutputSize != "" {
+			alphaVantage.OutputSize = config.AlphaVantageOutputSize
+		}
+		alphaVantage.SearchDir = filepath.Join(cacheDir(config), alphaVantage.Name())
+		source = alphaVantage
+	default:
+		return nil, fmt.Errorf("provider: unknown provider %q", config.Provider)
+	}
+
+	// limit the calls that reach the provider, not the ones the cache answers
+	perMinute := config.RequestsPerMinute[source.Name()]
+	if source.Name() == "alphavantage" && perMinute <= 0 {
+		perMinute = config.AlphaVantageCallsPerMinute
+		if perMinute <= 0 {
+			perMinute = AlphaVantageCallsPerMinute
+		}
+	}
+	if perMinute > 0 {
+		source = NewLimited(source, perMinute)
+	}
+
+	retries := DefaultRetries
+	if config.Retries != nil {
+		retries = *config.Retries
+	}
+	retryDelay, err := parseTTL(config.RetryDelay, DefaultRetryDelay)
+	if err != nil {
+		return nil, fmt.Errorf("provider: retryDelay - %s", err.Error())
+	}
+	source = NewRetrying(source, retries, retryDelay)
+
+	quoteTTL, err := parseTTL(config.QuoteTTL, DefaultQuoteTTL)
+	if err != nil {
+		return nil, fmt.Errorf("provider: quoteTTL - %s", err.Error())
+	}
+	historyTTL, err := parseTTL(config.HistoryTTL, DefaultHistoryTTL)
+	if err != nil {
+		return nil, fmt.Errorf("provider: historyTTL - %s", err.Error())
+	}
+	cache := NewCache(source, config.CacheDirectory, quoteTTL, historyTTL)
+	cache.Seed = seed
+	return cache, nil
+}
+
+// cacheDir is the cacheDirectory of the config, data/cache when not set
+func cacheDir(config utils.Config) string {
+	if config.CacheDirectory == "" {
+		return "data/cache"
+	}
+	return config.CacheDirectory
+}
+
+// parseTTL reads a Go duration from the config, defaultTTL when it is not set
+func parseTTL(value string, defaultTTL time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultTTL, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// suffixCurrencies are the currencies of the exchanges by the suffix of their symbols, London quotes in pence.
+var suffixCurrencies = map[string]string{
+	".TO": "CAD",
+	".V":  "CAD",
+	".NE": "CAD",
+	".L":  "GBX",
+	".PA": "EUR",
+	".DE": "EUR",
+	".AS": "EUR",
+	".MI": "EUR",
+	".MC": "EUR",
+	".SW": "CHF",
+	".T":  "JPY",
+	".HK": "HKD",
+	".AX": "AUD",
+	".ST": "SEK",
+	".OL": "NOK",
+	".CO": "DKK",
+	".JO": "ZAC",
+	".TA": "ILA",
+}
+
+// CurrencyFromSuffix is the currency of the exchange of the symbol, for when the source does not tell us.
+// The symbols without a known suffix are US ones.
+func CurrencyFromSuffix(symbol string) string {
+	if dot := strings.LastIndex(symbol, "."); dot >= 0 {
+		if currency, isIn := suffixCurrencies[strings.ToUpper(symbol[dot:])]; isIn {
+			return currency
+		}
+	}
+	return "USD"
+}
@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	DefaultRetries    = 3
+	DefaultRetryDelay = 2 * time.Second
+)
+
+type temporaryError struct {
+	error
+}
+
+func (e temporaryError) Temporary() bool {
+	return true
+}
+
+func (e temporaryError) Unwrap() error {
+	return e.error
+}
+
+// Temporary marks err as worth trying again, like a timeout or a rate limit.
+func Temporary(err error) error {
+	if err == nil {
+		return nil
+	}
+	return temporaryError{err}
+}
+
+// IsTemporary reports if err was marked with Temporary or is a network error.
+func IsTemporary(err error) bool {
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) && temporary.Temporary() {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// temporaryStatus is true for the http status codes that are worth a retry.
+func temporaryStatus(code int) bool {
+	return code == 429 || code >= 500
+}
+
+// Retrying calls Provider again when it fails with a temporary error, up to Retries more times,
+// waiting Delay before the first retry and doubling it after each one.
+type Retrying struct {
+	Provider Provider
+	Retries  int
+	Delay    time.Duration
+}
+
+func NewRetrying(source Provider, retries int, delay time.Duration) *Retrying {
+	return &Retrying{source, retries, delay}
+}
+
+func (r *Retrying) Name() string {
+	return r.Provider.Name()
+}
+
+func (r *Retrying) Current(symbol string) (Quote, error) {
+	var quote Quote
+	err := r.retry(func() (err error) {
+		quote, err = r.Provider.Current(symbol)
+		return err
+	})
+	return quote, err
+}
+
+func (r *Retrying) History(symbol string) (History, error) {
+	var history History
+	err := r.retry(func() (err error) {
+		history, err = r.Provider.History(symbol)
+		return err
+	})
+	return history, err
+}
+
+func (r *Retrying) retry(call func() error) error {
+	delay := r.Delay
+	err := call()
+	attempts := 1
+	for ; attempts <= r.Retries && IsTemporary(err); attempts++ {
+		time.Sleep(delay)
+		delay *= 2
+		err = call()
+	}
+	if err != nil && IsTemporary(err) {
+		if attempts == 1 {
+			return fmt.Errorf("%s (gave up after 1 attempt)", err.Error())
+		}
+		return fmt.Errorf("%s (gave up after %d attempts)", err.Error(), attempts)
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetrying(t *testing.T) {
+	busy := Temporary(errors.New("test: AAPL - 429 Too Many Requests"))
+	unknown := errors.New("test: AAPL - unknown symbol")
+	tests := []struct {
+		name    string
+		retries int
+		errs    []error
+		calls   int
+		err     string
+	}{
+		{"works", 3, nil, 1, ""},
+		{"works on a retry", 3, []error{busy, busy}, 3, ""},
+		{"gives up", 2, []error{busy, busy, busy, busy}, 3, "(gave up after 3 attempts)"},
+		{"never retries", 0, []error{busy}, 1, "(gave up after 1 attempt)"},
+		{"not temporary", 3, []error{unknown}, 1, "unknown symbol"},
+		{"network error", 1, []error{&net.DNSError{Err: "no such host", IsTimeout: true}}, 2, ""},
+	}
+	for _, test := range tests {
+		source := newTestSource(10, test.errs...)
+		r := NewRetrying(source, test.retries, time.Millisecond)
+
+		_, err := r.Current("AAPL")
+		if source.calls["AAPL"] != test.calls {
+			t.Errorf("%s: %d calls, want %d", test.name, source.calls["AAPL"], test.calls)
+		}
+		if test.err == "" && err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		}
+		if test.err != "" && (err == nil || !strings.HasSuffix(err.Error(), test.err)) {
+			t.Errorf("%s: error is %v, want it to end with %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestRetryingHistoryWaitsLonger(t *testing.T) {
+	busy := Temporary(errors.New("test: AAPL - 503 Service Unavailable"))
+	source := newTestSource(10, busy, busy)
+	r := NewRetrying(source, 2, 20*time.Millisecond)
+
+	start := time.Now()
+	if _, err := r.History("AAPL"); err != nil {
+		t.Fatal(err)
+	}
+	// 20ms then 40ms
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("the retries took %v, want at least 60ms", elapsed)
+	}
+	if source.calls["AAPL history"] != 3 {
+		t.Errorf("%d calls, want 3", source.calls["AAPL history"])
+	}
+}
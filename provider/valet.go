@@ -0,0 +1,208 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValetObservations is the json of the Bank of Canada Valet observations of a series, oldest first.
+// The rate of a day is under the name of the series: {"d": "2019-01-02", "FXUSDCAD": {"v": "1.3642"}}.
+type ValetObservations struct {
+	Message      string                       `json:"message"`
+	Observations []map[string]json.RawMessage `json:"observations"`
+}
+
+type valetValue struct {
+	V json.Number `json:"v"`
+}
+
+// Valet reads the exchange rates of the Bank of Canada Valet API. The history of the pair USDCAD is the series FXUSDCAD,
+// taken from <Dir>/FXUSDCAD.json or FXUSDCAD.csv when the file is there and from BaseURL/observations/FXUSDCAD/json
+// when not. The Bank only publishes rates into CAD: CADUSD is FXUSDCAD inverted and a pair without CAD is an error,
+// without asking the Valet for a series it does not have.
+type Valet struct {
+	BaseURL string
+	Dir     string
+}
+
+func NewValet(baseURL string, dir string) *Valet {
+	if baseURL == "" {
+		baseURL = "https://www.bankofcanada.ca/valet"
+	}
+	return &Valet{strings.TrimSuffix(baseURL, "/"), dir}
+}
+
+func (v *Valet) Name() string {
+	return "valet"
+}
+
+// Current is the rate of the latest day of the series, in the second currency of the pair.
+func (v *Valet) Current(symbol string) (Quote, error) {
+	history, err := v.History(symbol)
+	if err != nil {
+		return Quote{}, err
+	}
+	latest := history.Days[0]
+	quote := Quote{Symbol: symbol, Name: history.Name, Currency: symbol[len(symbol)-3:], Date: latest.Date, Price: latest.Close, Fetched: history.Fetched}
+	if len(history.Days) > 1 {
+		quote.CloseYesterday = history.Days[1].Close
+	}
+	return quote, nil
+}
+
+func (v *Valet) History(symbol string) (History, error) {
+	if len(symbol) != 6 {
+		return History{}, fmt.Errorf("valet: %s - not a currency pair like USDCAD", symbol)
+	}
+	symbol = strings.ToUpper(symbol)
+	from, to := symbol[:3], symbol[3:]
+	invert := false
+	if from == "CAD" && to != "CAD" {
+		from, to, invert = to, from, true
+	}
+	if to != "CAD" {
+		return History{}, fmt.Errorf("valet: %s - the Bank of Canada only has rates into CAD", symbol)
+	}
+	series := "FX" + from + to
+	body, fetched, isCSV, err := v.load(series)
+	if err != nil {
+		return History{}, err
+	}
+
+	var rates map[string]float64
+	if isCSV {
+		rates, err = parseValetCSV(body, series)
+	} else {
+		rates, err = parseValetJSON(body, series)
+	}
+	if err != nil {
+		return History{}, fmt.Errorf("valet: %s - %s", series, err.Error())
+	}
+	history := History{Symbol: symbol, Name: symbol[:3] + "/" + symbol[3:], Fetched: fetched}
+	for date, rate := range rates {
+		if invert {
+			rate = 1 / rate
+		}
+		history.Days = append(history.Days, Day{Date: date, Open: rate, Close: rate, High: rate, Low: rate})
+	}
+	sort.Slice(history.Days, func(i, j int) bool {
+		return history.Days[i].Date > history.Days[j].Date
+	})
+	if len(history.Days) == 0 {
+		return History{}, fmt.Errorf("valet: %s - no observations", series)
+	}
+	return history, nil
+}
+
+// load is the json or csv of the series, from its local file first, and when it was written.
+func (v *Valet) load(series string) ([]byte, time.Time, bool, error) {
+	if v.Dir != "" {
+		for _, ext := range []string{".json", ".csv"} {
+			file := filepath.Join(v.Dir, series+ext)
+			info, err := os.Stat(file)
+			if err != nil {
+				continue
+			}
+			body, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, time.Time{}, false, fmt.Errorf("valet: %s - %s", file, err.Error())
+			}
+			return body, info.ModTime(), ext == ".csv", nil
+		}
+	}
+
+	response, err := http.Get(v.BaseURL + "/observations/" + series + "/json")
+	if err != nil {
+		return nil, time.Time{}, false, Temporary(fmt.Errorf("valet: %s - %s", series, err.Error()))
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, time.Time{}, false, Temporary(fmt.Errorf("valet: %s - %s", series, err.Error()))
+	}
+	if response.StatusCode != http.StatusOK {
+		err := fmt.Errorf("valet: %s - %s", series, response.Status)
+		if temporaryStatus(response.StatusCode) {
+			err = Temporary(err)
+		}
+		return nil, time.Time{}, false, err
+	}
+	return body, time.Now(), false, nil
+}
+
+// parseValetJSON reads the rates by date of series from the observations json, the days without a value are skipped.
+func parseValetJSON(body []byte, series string) (map[string]float64, error) {
+	var observations ValetObservations
+	if err := json.Unmarshal(body, &observations); err != nil {
+		return nil, err
+	}
+	if observations.Message != "" && len(observations.Observations) == 0 {
+		return nil, fmt.Errorf("%s", observations.Message)
+	}
+	rates := make(map[string]float64)
+	for _, observation := range observations.Observations {
+		var date string
+		var value valetValue
+		if err := json.Unmarshal(observation["d"], &date); err != nil {
+			return nil, fmt.Errorf("invalid date %s", string(observation["d"]))
+		}
+		raw, isIn := observation[series]
+		if !isIn || json.Unmarshal(raw, &value) != nil {
+			continue
+		}
+		if rate, err := strconv.ParseFloat(string(value.V), 64); err == nil && rate > 0 {
+			rates[date] = rate
+		}
+	}
+	return rates, nil
+}
+
+// parseValetCSV reads the rates by date of series from the csv of the Valet, the rows after the "OBSERVATIONS" line.
+func parseValetCSV(body []byte, series string) (map[string]float64, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	rates := make(map[string]float64)
+	column := -1
+	for i, record := range records {
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "OBSERVATIONS" && i+1 < len(records) {
+			column = -1
+			for c, name := range records[i+1] {
+				if strings.TrimSpace(name) == series {
+					column = c
+				}
+			}
+			if column < 0 {
+				return nil, fmt.Errorf("no %s column", series)
+			}
+			continue
+		}
+		if column < 0 || len(record) <= column {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", record[0]); err != nil {
+			continue
+		}
+		if rate, err := strconv.ParseFloat(strings.TrimSpace(record[column]), 64); err == nil && rate > 0 {
+			rates[record[0]] = rate
+		}
+	}
+	if column < 0 {
+		return nil, fmt.Errorf("no OBSERVATIONS section")
+	}
+	return rates, nil
+}
@@ -0,0 +1,155 @@
+package provider
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// valetStandIn serves testdata/valet/FXUSDCAD.json for the FXUSDCAD observations, or the file of respond with status,
+// and counts the requests of each path.
+type valetStandIn struct {
+	t       *testing.T
+	respond string
+	status  int
+
+	mutex sync.Mutex
+	calls map[string]int
+}
+
+func (s *valetStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	s.calls[r.URL.Path]++
+	s.mutex.Unlock()
+
+	file := "FXUSDCAD.json"
+	if s.respond != "" {
+		file = s.respond
+	}
+	if r.URL.Path != "/observations/FXUSDCAD/json" {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "valet", file))
+	if err != nil {
+		s.t.Errorf("no recorded response %s: %s", file, err)
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+	}
+	w.Write(body)
+}
+
+func newValetStandIn(t *testing.T, respond string, status int) (*valetStandIn, *httptest.Server) {
+	standIn := &valetStandIn{t: t, respond: respond, status: status, calls: make(map[string]int)}
+	return standIn, httptest.NewServer(standIn)
+}
+
+func closeTo(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestValetHistory(t *testing.T) {
+	standIn, server := newValetStandIn(t, "", 0)
+	defer server.Close()
+
+	history, err := NewValet(server.URL+"/", "").History("usdcad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history.Symbol != "USDCAD" || history.Name != "USD/CAD" {
+		t.Errorf("history is of %s %s, want USDCAD", history.Symbol, history.Name)
+	}
+	// most recent first, the days without a value are left out
+	want := []Day{{"2019-01-07", 1.33, 1.33, 1.33, 1.33, 0}, {"2019-01-03", 1.361, 1.361, 1.361, 1.361, 0}, {"2019-01-02", 1.3642, 1.3642, 1.3642, 1.3642, 0}}
+	if len(history.Days) != len(want) {
+		t.Fatalf("days are %+v, want %+v", history.Days, want)
+	}
+	for i, day := range history.Days {
+		if day != want[i] {
+			t.Errorf("day %d is %+v, want %+v", i, day, want[i])
+		}
+	}
+	if standIn.calls["/observations/FXUSDCAD/json"] != 1 {
+		t.Errorf("requests are %v, want FXUSDCAD once", standIn.calls)
+	}
+}
+
+func TestValetInvertsTheRatesOutOfCAD(t *testing.T) {
+	standIn, server := newValetStandIn(t, "", 0)
+	defer server.Close()
+	v := NewValet(server.URL, "")
+
+	quote, err := v.Current("CADUSD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Currency != "USD" || quote.Date != "2019-01-07" || !closeTo(quote.Price, 1/1.33) || !closeTo(quote.CloseYesterday, 1/1.361) {
+		t.Errorf("quote is %+v, want the inverse of FXUSDCAD in USD", quote)
+	}
+	if _, err := v.History("USDEUR"); err == nil {
+		t.Error("no error for a pair without CAD")
+	}
+	// the Valet has no FXCADUSD or FXUSDEUR series to ask for
+	if len(standIn.calls) != 1 || standIn.calls["/observations/FXUSDCAD/json"] != 1 {
+		t.Errorf("requests are %v, want FXUSDCAD alone", standIn.calls)
+	}
+}
+
+func TestValetWithoutObservations(t *testing.T) {
+	tests := []struct {
+		respond   string
+		status    int
+		temporary bool
+	}{
+		{"empty.json", 0, false},
+		{"not_found.json", http.StatusNotFound, false},
+		{"not_found.json", http.StatusServiceUnavailable, true},
+	}
+	for _, test := range tests {
+		_, server := newValetStandIn(t, test.respond, test.status)
+		_, err := NewValet(server.URL, "").History("USDCAD")
+		if err == nil {
+			t.Errorf("%s %d: no error", test.respond, test.status)
+		} else if IsTemporary(err) != test.temporary {
+			t.Errorf("%s %d: temporary is %v, want %v: %s", test.respond, test.status, IsTemporary(err), test.temporary, err)
+		}
+		server.Close()
+	}
+}
+
+func TestValetFile(t *testing.T) {
+	standIn, server := newValetStandIn(t, "", 0)
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "valet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "valet", "FXUSDCAD.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "FXUSDCAD.csv"), body, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := NewValet(server.URL, dir).History("USDCAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Days) != 2 || history.Days[0].Close != 1.361 || history.Days[1].Close != 1.3642 {
+		t.Errorf("days are %+v, want 2019-01-03 and 2019-01-02", history.Days)
+	}
+	if len(standIn.calls) != 0 {
+		t.Errorf("requests are %v, want the file alone", standIn.calls)
+	}
+}
@@ -0,0 +1,223 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+type WorldTradingDataCurrent struct {
+	Message          string `json:"Message"`
+	SymbolsRequested int    `json:"symbols_requested"`
+	SymbolsReturned  int    `json:"symbols_returned"`
+	Data             []struct {
+		Symbol         string `json:"symbol"`
+		Name           string `json:"name"`
+		Currency       string `json:"currency"`
+		Price          string `json:"price"`
+		PriceOpen      string `json:"price_open"`
+		DayHigh        string `json:"day_high"`
+		DayLow         string `json:"day_low"`
+		Five2WeekHigh  string `json:"52_week_high"`
+		Five2WeekLow   string `json:"52_week_low"`
+		DayChange      string `json:"day_change"`
+		ChangePct      string `json:"change_pct"`
+		CloseYesterday string `json:"close_yesterday"`
+		MarketCap      string `json:"market_cap"`
+		Volume         string `json:"volume"`
+	} `json:"data"`
+}
+
+type WorldTradingDataHistory struct {
+	Message string `json:"Message"`
+	Name    string `json:"name"`
+	History []struct {
+		Date string `json:"date"`
+		Data struct {
+			Open   string `json:"open"`
+			Close  string `json:"close"`
+			High   string `json:"high"`
+			Low    string `json:"low"`
+			Volume string `json:"volume"`
+		} `json:"data"`
+	} `json:"history"`
+}
+
+// wtdResponse is a decoded response that can tell if it holds usable data
+type wtdResponse interface {
+	check() error
+}
+
+func (current *WorldTradingDataCurrent) check() error {
+	if current.Message != "" {
+		return errors.New(current.Message)
+	}
+	if len(current.Data) == 0 {
+		return fmt.Errorf("no current data")
+	}
+	if price, err := strconv.ParseFloat(current.Data[0].Price, 64); err != nil || price <= 0 {
+		return fmt.Errorf("invalid price %q", current.Data[0].Price)
+	}
+	return nil
+}
+
+func (history *WorldTradingDataHistory) check() error {
+	if history.Message != "" {
+		return errors.New(history.Message)
+	}
+	if len(history.History) == 0 {
+		return fmt.Errorf("no history")
+	}
+	return nil
+}
+
+// WorldTradingData reads the worldtradingdata.com json and keeps a copy of each download in Dir.
+// When UseLocalFiles is set the files of Dir seed the cache of a symbol that is not cached yet.
+type WorldTradingData struct {
+	Token         string
+	UseLocalFiles bool
+	Dir           string
+	BaseURL       string
+}
+
+func NewWorldTradingData(token string, useLocalFiles bool) *WorldTradingData {
+	return &WorldTradingData{token, useLocalFiles, "data/wtd", "https://www.worldtradingdata.com/api/v1"}
+}
+
+func (w *WorldTradingData) Name() string {
+	return "wtd"
+}
+
+func (w *WorldTradingData) Current(symbol string) (Quote, error) {
+	var current WorldTradingDataCurrent
+	fetched, err := w.download(symbol, "current", "stock", &current)
+	if err != nil {
+		return Quote{}, err
+	}
+	return w.quote(symbol, &current, fetched), nil
+}
+
+func (w *WorldTradingData) quote(symbol string, current *WorldTradingDataCurrent, fetched time.Time) Quote {
+	data := current.Data[0]
+	quote := Quote{Symbol: symbol, Name: data.Name, Currency: data.Currency, Fetched: fetched}
+	quote.Price, _ = strconv.ParseFloat(data.Price, 64)
+	quote.FiftyTwoWeekHigh, _ = strconv.ParseFloat(data.Five2WeekHigh, 64)
+	quote.FiftyTwoWeekLow, _ = strconv.ParseFloat(data.Five2WeekLow, 64)
+	quote.CloseYesterday, _ = strconv.ParseFloat(data.CloseYesterday, 64)
+	return quote
+}
+
+func (w *WorldTradingData) History(symbol string) (History, error) {
+	var wtdHistory WorldTradingDataHistory
+	fetched, err := w.download(symbol, "history", "history", &wtdHistory)
+	if err != nil {
+		return History{}, err
+	}
+	return w.history(symbol, &wtdHistory, fetched), nil
+}
+
+func (w *WorldTradingData) history(symbol string, wtdHistory *WorldTradingDataHistory, fetched time.Time) History {
+	history := History{Symbol: symbol, Name: wtdHistory.Name, Fetched: fetched}
+	for _, day := range wtdHistory.History {
+		var d Day
+		d.Date = day.Date
+		d.Open, _ = strconv.ParseFloat(day.Data.Open, 64)
+		d.Close, _ = strconv.ParseFloat(day.Data.Close, 64)
+		d.High, _ = strconv.ParseFloat(day.Data.High, 64)
+		d.Low, _ = strconv.ParseFloat(day.Data.Low, 64)
+		d.Volume, _ = strconv.ParseFloat(day.Data.Volume, 64)
+		history.Days = append(history.Days, d)
+	}
+	return history
+}
+
+// SeedCurrent is the quote of data/wtd/<symbol>-current.json when UseLocalFiles is set, fetched when the file was written.
+func (w *WorldTradingData) SeedCurrent(symbol string) (Quote, bool) {
+	var current WorldTradingDataCurrent
+	fetched, ok := w.local(symbol, "current", &current)
+	if !ok {
+		return Quote{}, false
+	}
+	return w.quote(symbol, &current, fetched), true
+}
+
+// SeedHistory is the history of data/wtd/<symbol>-history.json when UseLocalFiles is set.
+func (w *WorldTradingData) SeedHistory(symbol string) (History, bool) {
+	var wtdHistory WorldTradingDataHistory
+	fetched, ok := w.local(symbol, "history", &wtdHistory)
+	if !ok {
+		return History{}, false
+	}
+	return w.history(symbol, &wtdHistory, fetched), true
+}
+
+// download fills v from the endpoint and keeps the response in data/wtd/<symbol>-<kind>.json.
+// A download that is not a valid response never replaces the file.
+func (w *WorldTradingData) download(symbol string, kind string, endpoint string, v wtdResponse) (time.Time, error) {
+	if w.UseLocalFiles {
+		println("Getting WDT for " + symbol)
+	}
+	url := w.BaseURL + "/" + endpoint + "?symbol=" + symbol + "&api_token=" + w.Token + "&formatted=false"
+	response, err := http.Get(url)
+	if err != nil {
+		return time.Time{}, Temporary(fmt.Errorf("wtd: %s - %s", symbol, err.Error()))
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return time.Time{}, Temporary(fmt.Errorf("wtd: %s - %s", symbol, err.Error()))
+	}
+	if response.StatusCode != http.StatusOK {
+		err := fmt.Errorf("wtd: %s - %s", symbol, response.Status)
+		if temporaryStatus(response.StatusCode) {
+			err = Temporary(err)
+		}
+		return time.Time{}, err
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		return time.Time{}, fmt.Errorf("wtd: %s - %s", symbol, err.Error())
+	}
+	if err = v.check(); err != nil {
+		return time.Time{}, fmt.Errorf("wtd: %s - %s", symbol, err.Error())
+	}
+	if err = writeFileAtomic(w.file(symbol, kind), body, 0666); err != nil {
+		return time.Time{}, fmt.Errorf("wtd: %s - %s", symbol, err.Error())
+	}
+	return time.Now(), nil
+}
+
+// local fills v from data/wtd/<symbol>-<kind>.json when UseLocalFiles is set and returns when the file was written.
+// False when there is no such file or it is not a valid response.
+func (w *WorldTradingData) local(symbol string, kind string, v wtdResponse) (time.Time, bool) {
+	if !w.UseLocalFiles {
+		return time.Time{}, false
+	}
+	jsonFile := w.file(symbol, kind)
+	info, err := os.Stat(jsonFile)
+	if err != nil {
+		return time.Time{}, false
+	}
+	raw, err := ioutil.ReadFile(jsonFile)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if err = json.Unmarshal(raw, v); err != nil {
+		println("wtd: " + jsonFile + " - " + err.Error())
+		return time.Time{}, false
+	}
+	if err = v.check(); err != nil {
+		println("wtd: " + jsonFile + " - " + err.Error())
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
+
+func (w *WorldTradingData) file(symbol string, kind string) string {
+	return filepath.Join(w.Dir, symbol+"-"+kind+".json")
+}
@@ -1,729 +1,497 @@
 package main
 
 import (
+	"github.com/kmorin72/stock/portfolio"
+	"github.com/kmorin72/stock/provider"
 	"github.com/kmorin72/stock/utils"
 	"fmt"
-	"encoding/json"
-	"time"
-	"strconv"
-	"net/http"
 	"io/ioutil"
 	"os"
-	"sort"
-	"strings"
 	"path/filepath"
+	"strings"
+	"strconv"
+	"sort"
 	"log"
+	"flag"
+	"time"
 )
 
-var useFilesFirst = true	
-var wtdToken = ""
-
-type WorldTradingDataCurrent struct {
-	SymbolsRequested int `json:"symbols_requested"`
-	SymbolsReturned  int `json:"symbols_returned"`
-	Data             []struct {
-		Symbol         string `json:"symbol"`
-		Name           string `json:"name"`
-		Currency       string `json:"currency"`
-		Price          string `json:"price"`
-		PriceOpen      string `json:"price_open"`
-		DayHigh        string `json:"day_high"`
-		DayLow         string `json:"day_low"`
-		Five2WeekHigh  string `json:"52_week_high"`
-		Five2WeekLow   string `json:"52_week_low"`
-		DayChange      string `json:"day_change"`
-		ChangePct      string `json:"change_pct"`
-		CloseYesterday string `json:"close_yesterday"`
-		MarketCap      string `json:"market_cap"`
-		Volume         string `json:"volume"`
-	} `json:"data"`
-}
-
-type WorldTradingDataHistory struct {
-	Name    string `json:"name"`
-	History []struct {
-		//Date string `json:"date"`
-		Date string `json:"date"`
-		Data struct {
-			Open   string `json:"open"`
-			Close  string `json:"close"`
-			High   string `json:"high"`
-			Low    string `json:"low"`
-			Volume string `json:"volume"`
-		} `json:"data"`
-	} `json:"history"`
-}
-
-type TransactionsData struct {
-	Transactions []struct {
-		Symbol   string 	`json:"symbol"`
-		Type     string 	`json:"type"`
-		Date     string 	`json:"date"`
-		Quantity int 		`json:"quantity"`
-		Price    float64 	`json:"price"`
-	} `json:"transactions"`
-}
-
-type StockEvent struct {
-	Type 		string
-	Quantity	int
-	Amount		float64
-	SplitTo		int
-	SplitFrom	int
-}
-
-type Stock struct {
-	Symbol         		string
-	Name           		string
-	Currency       		string
-	Price          		float64
-	FiftyTwoWeekHigh 	float64
-	Buys          		map[string]Tx
-	Sells          		map[string]Tx
-	Dividends      		map[string]Dividend
-	Splits         		map[string]Split
-	Timeline       		map[string][]StockEvent
-	HistoricalData 		WorldTradingDataHistory
-	TLR					TimeLineResult
-	ROI					ReturnOnInvestment
-}
-
-type Split struct {
-	Symbol string	`json:"symbol"`
-	Date   string 	`json:"date"`
-	To     int		`json:"to"`
-	From   int		`json:"from"`
-}
-
-type SplitData struct {
-	Splits []Split
+const usage = `usage: stock <command> [flags] [symbols]
+
+commands:
+  report    write the dividend summary, stock_summary.csv and the details files (the default)
+  timeline  print the events of the given symbols
+  screen    print the returns of the given symbols, the watchlist of the config when none are given
+  fetch     get the quotes and history of the ledger symbols into the cache
+  lots      print the open lots of the given symbols and the sales taken from each lot
+  8949      print the US gains and wash sales of the accounts with washSales and write the form 8949 csv of the year
+  gains     print the capital gains and superficial losses by year and write the Schedule 3 csv of each year
+  value     print the time-weighted returns of the symbols and the portfolio and write their daily value csv
+  validate  check the config, splits, dividend and transaction files
+
+Run stock <command> -h for the flags of a command.
+`
+
+// options are the flags every command takes
+type options struct {
+	config       string
+	output       string
+	data         string
+	transactions string
+	symbols      string
+	refresh      string
 }
 
-type Tx struct {
-	Date     string
-	Quantity int
-	Price    float64
+func newFlagSet(name string, o *options) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.StringVar(&o.config, "config", "conf/config.json", "config file")
+	flags.StringVar(&o.output, "output", "output", "directory the report files are written to")
+	flags.StringVar(&o.data, "data", "data", "directory with splits.json and the dividends")
+	flags.StringVar(&o.transactions, "transactions", "private/transactions.json", "transactions file")
+	flags.StringVar(&o.symbols, "symbols", "", "only these symbols, comma separated")
+	flags.StringVar(&o.refresh, "refresh", "", "symbols to get from the provider again even if cached, comma separated")
+	return flags
 }
 
-type Dividend struct {
-	Date   	string 	`json:"date"`
-	Amount	float64 `json:"amount"`
+// selected are the symbols of -symbols and the ones after the flags
+func (o options) selected(flags *flag.FlagSet) []string {
+	return append(splitSymbols(o.symbols), flags.Args()...)
 }
 
-type DividendData struct {
-	Symbol		string		`json:"symbol"`
-	Dividends 	[]Dividend	`json:"dividends"`
-}
-
-type ReturnOnInvestment struct {
-	threeDays	float64
-	oneWeek 	float64
-	twoWeeks 	float64
-	oneMonth 	float64
-	twoMonths 	float64
-	sixMonth 	float64
-	oneyear 	float64
-	twoyears 	float64
-}
-
-type TimeLineResult struct {
-	NumberOfShares 		int
-	AveragePrice		float64
-	DividendPaid		float64
-	DividendPerYear		map[string]float64
-	DividendHikes		int
-	DividendLastYear	float64
-	RealizedGains		float64
-}
-
-var Stocks 						= make(map[string]Stock)
-var GlobalDividendPerYear_CAD	= make(map[string]float64)
-var GlobalDividendPerYear_USD	= make(map[string]float64)
-var GlobalDividend1Month_CAD 	float64
-var GlobalDividend6Months_CAD 	float64
-var GlobalDividend1Year_CAD 	float64
-var GlobalDividend1Month_USD 	float64
-var GlobalDividend6Months_USD 	float64
-var GlobalDividend1Year_USD 	float64
-
-
-func calculateROISince(price float64, target time.Time, history WorldTradingDataHistory) float64 {
-
-	// iterate the history until you hit the date or something before to get the ROI
-	for _, day := range history.History {
-		t, _ := time.Parse("2006-01-02", day.Date)
-
-		// if the target is not after, it is the same or before
-		if !target.Before(t) {
-			oldPrice, _ := strconv.ParseFloat(day.Data.Close, 64)
-			return (price/oldPrice - 1) * 100
+func splitSymbols(list string) []string {
+	symbols := []string{}
+	for _, symbol := range strings.Split(list, ",") {
+		if symbol = strings.TrimSpace(symbol); symbol != "" {
+			symbols = append(symbols, symbol)
 		}
 	}
-	return -100
+	return symbols
 }
 
-// returns -1 as third parameter if unable to get the data
-func GetWorldTradingData(symbol string) (WorldTradingDataCurrent, WorldTradingDataHistory) {
-
-	if useFilesFirst {
-		println("Getting WDT for " + symbol)
-	}
-
-	// handle the current data
-	var current WorldTradingDataCurrent
-	currentJsonFile := "data/wtd/" + symbol + "-current.json"
-	_, err := os.Stat(currentJsonFile)
-	currentJsonFileNotExists := os.IsNotExist(err)
-
-	if currentJsonFileNotExists || !useFilesFirst {
-
-		// get it from the site and write the to file
-		url := "https://www.worldtradingdata.com/api/v1/stock?symbol=" + symbol + "&api_token=" + wtdToken + "&formatted=false"
-		response, err := http.Get(url)
-		if err != nil {
-			fmt.Println("stock: " + symbol + " - " + err.Error())
-			os.Exit(1)
-		}
-		//if err := json.NewDecoder(response.Body).Decode(&current); err != nil {
-		//	fmt.Println("stock: " + symbol + " - " + err.Error())
-		//	os.Exit(1)
-		//}
-		body, err := ioutil.ReadAll(response.Body)
-		if err = ioutil.WriteFile(currentJsonFile, body, 0666); err != nil {
-			fmt.Println("stock: " + symbol + " - " + err.Error())
-			os.Exit(1)
-		}
-	}
-
-	currentJson, err := ioutil.ReadFile(currentJsonFile)
+func loadMarketData(o options) (utils.Config, provider.Provider) {
+	userInputs := utils.LoadConfiguration(o.config)
+	marketData, err := provider.FromConfig(userInputs)
 	if err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
-	}
-	err = json.Unmarshal(currentJson, &current)
-	if err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
+		log.Fatal(err)
 	}
-
-
-	// handle historical data
-	var history WorldTradingDataHistory
-	historyJsonFile := "data/wtd/" + symbol + "-history.json"
-	_, err = os.Stat(historyJsonFile)
-	historyJsonFileNotExists := os.IsNotExist(err)
-
-	if (historyJsonFileNotExists || !useFilesFirst) {
-
-		url := "https://www.worldtradingdata.com/api/v1/history?symbol=" + symbol + "&api_token=" + wtdToken + "&formatted=false"
-		response, err := http.Get(url)
-		if err != nil {
-			fmt.Println("stock: " + symbol + " - " + err.Error())
-			os.Exit(1)
+	if cache, isCache := marketData.(*provider.Cache); isCache {
+		for _, symbol := range splitSymbols(o.refresh) {
+			cache.ForceRefresh(symbol)
 		}
-		//if err := json.NewDecoder(response.Body).Decode(&history); err != nil {
-		//	fmt.Println("stock: " + symbol + " - " + err.Error())
-		//	os.Exit(1)
-		//}
-		body, err := ioutil.ReadAll(response.Body)
-		if err = ioutil.WriteFile(historyJsonFile, body, 0666); err != nil {
-			fmt.Println("stock: " + symbol + " - " + err.Error())
-			os.Exit(1)
-		}
-
 	}
+	return userInputs, marketData
+}
 
-	historyJson, err := ioutil.ReadFile(historyJsonFile)
+func loadHousehold(o options, flags *flag.FlagSet, lotMethod string) *portfolio.Household {
+	household, err := portfolio.LoadHousehold(o.data, o.transactions)
 	if err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
+		log.Fatal(err)
 	}
-	err = json.Unmarshal(historyJson, &history)
-	if err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
+	household.Keep(o.selected(flags))
+	if lotMethod != "" {
+		household.SetLotMethod(lotMethod)
 	}
-
-	return current, history
+	return household
 }
 
-func getStock(symbol string) Stock {
-
-	// find the stock if it does not exist, create one.
-	if _, isIn := Stocks[symbol]; !isIn {
-		var tr TimeLineResult
-		var roi ReturnOnInvestment
-		var wdh	WorldTradingDataHistory
-		Stocks[symbol] = Stock{symbol, symbol, "CAD", 0.0, 0, make(map[string]Tx), make(map[string]Tx), make(map[string]Dividend), make(map[string]Split), make(map[string][]StockEvent), wdh, tr, roi}
+// loadConverter converts into the reportingCurrency of the config with the rates of its fxProvider
+func loadConverter(userInputs utils.Config) *portfolio.Converter {
+	rates, err := provider.FXFromConfig(userInputs)
+	if err != nil {
+		log.Fatal(err)
 	}
-	return Stocks[symbol]
+	return portfolio.NewConverter(strings.ToUpper(userInputs.ReportingCurrency), rates)
 }
 
-func addStockEvent(stock Stock, date string, event StockEvent) {
-	//t := date.Format("2006-01-02")
-	if _, isIn := stock.Timeline[date]; !isIn {
-		stock.Timeline[date] = []StockEvent{}
+// accountName is how the reports call an account, the transactions without one are "no account"
+func accountName(name string) string {
+	if name == "" {
+		return "no account"
 	}
-	stock.Timeline[date] = append(stock.Timeline[date], event)
+	return name
 }
 
-func populateStocks() {
-
-	// add the splits
-	rawSplits, err := ioutil.ReadFile("./data/splits.json")
+func report(args []string) {
+	var o options
+	flags := newFlagSet("report", &o)
+	flags.Parse(args)
+
+	userInputs, marketData := loadMarketData(o)
+	household := loadHousehold(o, flags, userInputs.LotMethod)
+	household.SetWashSales(userInputs.WashSales)
+	household.FetchMarketData(marketData, userInputs.Workers)
+	stocks := household.Total()
+	benchmarks, err := portfolio.LoadBenchmarks(o.data, provider.FetchAll(marketData, userInputs.Benchmarks, userInputs.Workers), stocks.Now)
 	if err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
-	}
-	var splitData SplitData
-	json.Unmarshal(rawSplits, &splitData)
-	for _, split := range splitData.Splits {
-		stock := getStock(split.Symbol)
-		stock.Splits[split.Date] = split
-		addStockEvent(stock, split.Date, StockEvent{"split", 0, 0, split.To, split.From})
+		log.Fatal(err)
 	}
 
-	// add the dividends
-	filepath.Walk("./data/dividends", func(path string, info os.FileInfo, err error) error {
-
-		if !info.IsDir() {
-			rawDividends, err := ioutil.ReadFile(path)
-			if err != nil {
-				fmt.Println(err.Error())
-				os.Exit(1)
-			}
-			var dividendData DividendData
-			json.Unmarshal(rawDividends, &dividendData)
-			for _, dividend := range dividendData.Dividends {
-
-				if strings.Index(dividend.Date, "-") == -1 {
-					t, _ := time.Parse("01/02/06", dividend.Date)
-					dividend.Date = t.Format("2006-01-02")
-				}
-
-				stock := getStock(dividendData.Symbol)
-				stock.Dividends[dividend.Date] = dividend
-				addStockEvent(stock, dividend.Date, StockEvent{"dividend", 0, dividend.Amount, 0, 0})
-			}
+	// every account in its own directory, then the household added up
+	if household.HasAccounts() {
+		for _, name := range household.Names() {
+			account := household.Accounts[name]
+			fmt.Print("\n\nAccount " + accountName(name) + "\n\n" + portfolio.GetDividendSummaryString(account.DividendTotals()))
+			fmt.Print(account.GetXIRRString())
+			writeReport(filepath.Join(o.output, strings.Replace(accountName(name), " ", "_", -1)), account, benchmarks, "")
 		}
-		return nil
-	})
-
+		fmt.Print("\n\nHousehold")
+	}
 
-	// add the transactions
-	rawTransactions, err := ioutil.ReadFile("./private/transactions.json")
-	if err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
+	fmt.Print("\n\n" + portfolio.GetDividendSummaryString(stocks.DividendTotals()))
+	fmt.Print(stocks.GetXIRRString())
+	fmt.Print(stocks.GetFeesString())
+	var converter *portfolio.Converter
+	if userInputs.ReportingCurrency != "" {
+		converter = loadConverter(userInputs)
+		fmt.Print(portfolio.GetConsolidatedString(household.Consolidate(converter)))
+	}
+	if len(benchmarks) > 0 {
+		fmt.Print(benchmarkComparison(household, benchmarks, converter))
 	}
 
-	var transactionData TransactionsData
-	json.Unmarshal(rawTransactions, &transactionData)
-	for _, transaction := range transactionData.Transactions {
-		stock := getStock(transaction.Symbol)
+	errors_str := stocks.GetErrorsString()
+	if errors_str != "" {
+		fmt.Print("\n" + errors_str)
+	}
+	writeReport(o.output, stocks, benchmarks, errors_str)
+}
 
-		// run the split adjustments for each transaction here ...
-		// order the splits from first to last and then apply them on the transactions (thinking about it, the order does not matter, but oh well, code is already there...)
-		if len(stock.Splits) > 0 {
-			dates := []string{}
-			for k, _ := range stock.Splits {
-				dates = append(dates, k)
-			}
-			sort.Strings(dates)
-			for _, date := range dates {
-				split_t, _ := time.Parse("2006-01-02", date)
-				tx_t, _ := time.Parse("2006-01-02", transaction.Date)
-				if tx_t.Before(split_t) {
-					//println(tx_t.Format("2006-01-02") + " - " + transaction.Date + " is before " + split_t.Format("2006-01-02)"))
-					split := stock.Splits[date]
-					transaction.Quantity 	= transaction.Quantity * split.To / split.From
-					transaction.Price		= transaction.Price * float64(split.From) / float64(split.To)
-				}
+// benchmarkComparison compares the household in each currency, and the whole household in the reportingCurrency,
+// with the benchmarks in that currency. The returns of a benchmark are in its own currency, without the exchange rate.
+func benchmarkComparison(household *portfolio.Household, benchmarks []portfolio.Benchmark, converter *portfolio.Converter) string {
+	str := "\nBenchmarks (time-weighted return of the portfolio, total return of the benchmarks)\n"
+	byCurrency := household.ValueSeries()
+	currencies := []string{}
+	for currency := range byCurrency {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	// the benchmarks that could not be fetched are listed with the total
+	inCurrency := func(currency string) []portfolio.Benchmark {
+		same := []portfolio.Benchmark{}
+		for _, benchmark := range benchmarks {
+			if benchmark.Currency == currency || benchmark.FetchError != "" && converter != nil && currency == converter.Currency {
+				same = append(same, benchmark)
 			}
 		}
+		return same
+	}
+	for _, currency := range currencies {
+		if same := inCurrency(currency); len(same) > 0 {
+			str += portfolio.GetBenchmarkString(currency+" portfolio", portfolio.PortfolioROI(byCurrency[currency]), same)
+		}
+	}
+	if converter != nil {
+		if same := inCurrency(converter.Currency); len(same) > 0 {
+			series := household.ValueSeriesIn(converter)
+			str += portfolio.GetBenchmarkString("Total "+converter.Currency, portfolio.PortfolioROI(series), same)
+		}
+	}
+	return str
+}
 
-		if strings.Compare(transaction.Type, "buy") == 0 {
-			stock.Buys[transaction.Date] = Tx{transaction.Date, transaction.Quantity, transaction.Price}
-			addStockEvent(stock, transaction.Date, StockEvent{"buy", transaction.Quantity, transaction.Price, 0, 0})
+// writeReport writes stock_summary.csv and the details files of the stocks into dir, errors_str at the top of the details.
+// The benchmarks are the last rows of stock_summary.csv.
+func writeReport(dir string, stocks *portfolio.Portfolio, benchmarks []portfolio.Benchmark, errors_str string) {
+	stock_summary_str := portfolio.GetStockSummaryHeader()
+	active_stocks_str := errors_str
+	inactive_stocks_str := errors_str
+
+	for _, k := range stocks.Symbols() {
+		if stocks.Stocks[k].TLR.NumberOfShares > 0 {
+			stock_summary_str += portfolio.GetStockSummaryRow(stocks.Stocks[k])
+			active_stocks_str += portfolio.GetStockDetailsString(stocks.Stocks[k], stocks.Now)
 		} else {
-			stock.Sells[transaction.Date] = Tx{transaction.Date, transaction.Quantity, transaction.Price}
-			addStockEvent(stock, transaction.Date, StockEvent{"sell", transaction.Quantity, transaction.Price, 0, 0})
+			inactive_stocks_str += portfolio.GetStockDetailsString(stocks.Stocks[k], stocks.Now)
 		}
 	}
-
-// todo iterate map differently, updates are not making it in here ...
-	symbols := []string{}
-	for symbol, _ := range Stocks {
-		symbols = append(symbols, symbol)
+	for _, benchmark := range benchmarks {
+		stock_summary_str += portfolio.GetBenchmarkSummaryRow(benchmark)
 	}
 
-	for _, symbol := range symbols {
-
-		stock, _ := Stocks[symbol]
-
-		// find the first purchase and get the stock with the history since that
-		if len((stock.Buys)) == 0 {
-			delete(Stocks, stock.Symbol)
-			continue
-		}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Fatal(err)
+	}
+	writeOutput(filepath.Join(dir, "stock_summary.csv"), stock_summary_str)
+	writeOutput(filepath.Join(dir, "active_stock_details.txt"), active_stocks_str)
+	writeOutput(filepath.Join(dir, "inactive_stock_details.txt"), inactive_stocks_str)
+}
 
-		//firstBuy := "3000-01-01"
-		//for k, _ := range stock.Buys {
-		//	if strings.Compare(k, firstBuy) == -1 {
-		//		firstBuy = k
-		//	}
-		//}
-		current, history := GetWorldTradingData(stock.Symbol)
-		stock.Name = current.Data[0].Name
-		stock.Currency = current.Data[0].Currency
-		stock.Price, _ = strconv.ParseFloat(current.Data[0].Price, 64)
-		stock.FiftyTwoWeekHigh, _ = strconv.ParseFloat(current.Data[0].Five2WeekHigh, 64)
-		stock.HistoricalData = history
-
-		// get the results based on timeline
-		stock = processTimeline(stock)
-
-		// fill in the ROI for
-		now := time.Now()
-
-		var roi ReturnOnInvestment
-		roi.threeDays 	= calculateROISince(stock.Price, now.AddDate(0, 0, -3), 	stock.HistoricalData)
-		roi.oneWeek 	= calculateROISince(stock.Price, now.AddDate(0, 0, -7), 	stock.HistoricalData)
-		roi.twoWeeks 	= calculateROISince(stock.Price, now.AddDate(0, 0, -14), 	stock.HistoricalData)
-		roi.oneMonth 	= calculateROISince(stock.Price, now.AddDate(0, -1, 0), 	stock.HistoricalData)
-		roi.twoMonths 	= calculateROISince(stock.Price, now.AddDate(0, -2, 0), 	stock.HistoricalData)
-		roi.sixMonth 	= calculateROISince(stock.Price, now.AddDate(0, -6, 0), 	stock.HistoricalData)
-		roi.oneyear 	= calculateROISince(stock.Price, now.AddDate(-1, 0, 0), 	stock.HistoricalData)
-		roi.twoyears 	= calculateROISince(stock.Price, now.AddDate(-2, 0, 0), 	stock.HistoricalData)
-		stock.ROI = roi
-
-		// todo figure out why this is needed ...
-		Stocks[symbol] = stock
+func writeOutput(file string, str string) {
+	if err := ioutil.WriteFile(file, []byte(str), 0644); err != nil {
+		log.Fatal(err)
 	}
 }
 
+func timeline(args []string) {
+	var o options
+	flags := newFlagSet("timeline", &o)
+	flags.Parse(args)
 
-func processTimeline(stock Stock) Stock {
-	firstPurchaseFound	:= false
-	AmountInvested		:= 0.0
-	LastDividendAmount	:= 0.0
-	tr 					:= TimeLineResult{0, 0, 0, make(map[string]float64), 0, 0, 0}
-
-
-	// create a slice of keys strings
-	var keys []string
-	for k, _ := range stock.Timeline {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-	oneMonthAgo := time.Now().AddDate(0, -1, 0)
-	sixMonthAgo := time.Now().AddDate(0, -6, 0)
-	oneYearAgo 	:= time.Now().AddDate(-1, 0, 0)
-	for _, key := range keys {
-		events := stock.Timeline[key]
-		for _, event := range events {
-			switch event.Type {
-			case "buy":
-				firstPurchaseFound = true
-				tr.AveragePrice = (tr.AveragePrice * float64(tr.NumberOfShares) + event.Amount * float64(event.Quantity)) / (float64(tr.NumberOfShares) + float64(event.Quantity))
-				AmountInvested += event.Amount * float64(event.Quantity)
-				tr.NumberOfShares += event.Quantity
-			case "sell":
-				tr.NumberOfShares -= event.Quantity
-				tr.RealizedGains += (float64(event.Quantity) * event.Amount) - (float64(event.Quantity) * tr.AveragePrice)
-			case "split":
-				//tr.NumberOfShares 		= tr.NumberOfShares * event.SplitTo / event.SplitFrom
-				//tr.AveragePrice		= tr.AveragePrice * float64(event.SplitFrom) / float64(event.SplitTo)
-				//LastDividendAmount	= LastDividendAmount * float64(event.SplitFrom) / float64(event.SplitTo)
-			case "dividend":
-				if (firstPurchaseFound) {
-					date, _ := time.Parse("2006-01-02", key)
-					year := (strings.Split(key, "-"))[0]
-					payout := float64(tr.NumberOfShares) * event.Amount
-					tr.DividendPerYear[year] += payout
-					if strings.Compare(stock.Currency, "CAD") == 0 {
-						GlobalDividendPerYear_CAD[year] += payout
-					} else {
-						GlobalDividendPerYear_USD[year] += payout
-					}
-					if (date.After(oneYearAgo)) {
-						tr.DividendLastYear += payout
-						if strings.Compare(stock.Currency, "CAD") == 0 {
-							GlobalDividend1Year_CAD += payout
-						} else {
-							GlobalDividend1Year_USD += payout
-						}
-					}
-					if (date.After(sixMonthAgo)) {
-						if strings.Compare(stock.Currency, "CAD") == 0 {
-							GlobalDividend6Months_CAD += payout
-						} else {
-							GlobalDividend6Months_USD += payout
-						}
-					}
-					if (date.After(oneMonthAgo)) {
-						if strings.Compare(stock.Currency, "CAD") == 0 {
-							GlobalDividend1Month_CAD += payout
-						} else {
-							GlobalDividend1Month_USD += payout
-						}
-					}
-					tr.DividendPaid += payout
-					if (event.Amount > (LastDividendAmount + .005)) {
-						tr.DividendHikes++
-						LastDividendAmount = event.Amount
-					}
-				}
-			default:
-				fmt.Println("ERROR - unexpected stock event type.")
+	symbols := o.selected(flags)
+	if len(symbols) == 0 {
+		log.Fatal("timeline: give the symbols to print")
+	}
+	household := loadHousehold(o, flags, "")
+	for _, name := range household.Names() {
+		if household.HasAccounts() {
+			fmt.Println("Account " + accountName(name))
+		}
+		for _, symbol := range symbols {
+			stock, isIn := household.Accounts[name].Stocks[symbol]
+			if !isIn {
+				fmt.Println("Stock : " + symbol + " - not in the transactions")
+				continue
 			}
+			fmt.Print(portfolio.GetTimelineString(stock))
 		}
 	}
-	stock.TLR = tr
-	return stock
 }
 
-func GetStockSummaryHeader() string {
-	return "Symbol, Currency, Shares, AvgPrice, BookValue, Price, MarketValue, Divy, 1 year, Hikes, Gain, Gain%, 52WHigh, (% from high), 3d, 7d, 14d, 1m, 2m, 6m, 1y, 2y\n"
-}
+func screen(args []string) {
+	var o options
+	flags := newFlagSet("screen", &o)
+	flags.Parse(args)
 
-func GetStockSummaryRow(stock Stock) string {
-	tr  := stock.TLR
-	roi := stock.ROI
-
-	//return ", , , , , , Divy, DivyHikes, Gain, 3d, 7d, 14d, 1m, 2m, 6m, 1y, 2y"
-	bv := float64(tr.NumberOfShares) * tr.AveragePrice
-	mv := float64(tr.NumberOfShares) * stock.Price
-	gp := (stock.Price/tr.AveragePrice - 1) * 100
-	fiftytwop := (stock.Price/stock.FiftyTwoWeekHigh - 1) * 100
-	str := fmt.Sprintf(stock.Symbol + ", " + stock.Currency + ", %d, %.2f, %.2f, %.2f, %.2f, %.2f, %.2f, %d, %.2f, %.2f%%, %.2f, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %.2f%%, %.2f%%\n",
-		tr.NumberOfShares, tr.AveragePrice, bv, stock.Price, mv, tr.DividendPaid, tr.DividendLastYear, tr.DividendHikes, mv-bv, gp, stock.FiftyTwoWeekHigh, fiftytwop, roi.threeDays, roi.oneWeek, roi.twoWeeks, roi.oneMonth, roi.twoMonths, roi.sixMonth, roi.oneyear, roi.twoyears)
-	return str
+	userInputs, marketData := loadMarketData(o)
+	symbols := o.selected(flags)
+	if len(symbols) == 0 {
+		symbols = userInputs.Watchlist
+	}
+	fmt.Print(portfolio.GetScreenString(marketData, symbols, userInputs.Workers, time.Now()))
 }
 
-func GetStockDetailsString(stock Stock) string {
-	//str := fmt.Sprintf("Symbol          : " + stock.Symbol + "    (" + stock.Currency + ")\n")
-	str := fmt.Sprintf("Symbol          : %9s    (" + stock.Currency + ")\n", stock.Symbol)
-	if stock.TLR.NumberOfShares > 0 {
-		str += fmt.Sprintf("Shares          : %9d\n", stock.TLR.NumberOfShares)
-		str += fmt.Sprintf("Average Price   : %9.2f    [%9.2f]\n", stock.TLR.AveragePrice, float64(stock.TLR.NumberOfShares)*stock.TLR.AveragePrice)
-		pnl := (stock.Price/stock.TLR.AveragePrice - 1) * 100
-		str += fmt.Sprintf("Current Price   : %9.2f    [%8.2f%%]\n", stock.Price, pnl)
-		str += fmt.Sprintf("Market Value    : %9.2f    [%9.2f]\n", float64(stock.TLR.NumberOfShares)*stock.Price, float64(stock.TLR.NumberOfShares)*stock.Price - float64(stock.TLR.NumberOfShares)*stock.TLR.AveragePrice)
-	} else {
-		str += fmt.Sprintf("Current Price   : %9.2f\n", stock.Price)
-		// get the average sale price and the last sale price
-		lastSale := time.Now().AddDate(-20, 0, 0)
-		lastSaleAmount := 0.0
-		totalQuantity := 0
-		totalSale := 0.0
-		for date, tx := range stock.Sells {
-			totalSale += float64(tx.Quantity) * tx.Price
-			totalQuantity += tx.Quantity
-			t, _ := time.Parse("2006-01-02", date)
-			if t.After(lastSale) {
-				lastSale = t
-				lastSaleAmount = tx.Price
-			}
+func fetch(args []string) {
+	var o options
+	flags := newFlagSet("fetch", &o)
+	flags.Parse(args)
+
+	userInputs, marketData := loadMarketData(o)
+	symbols := o.selected(flags)
+	if len(symbols) == 0 {
+		symbols = loadHousehold(o, flags, "").Symbols()
+	}
+	failed := 0
+	for _, result := range provider.FetchAll(marketData, symbols, userInputs.Workers) {
+		if result.Err != nil {
+			failed++
+			fmt.Printf("%-12s unavailable - %s\n", result.Symbol, result.Err.Error())
+			continue
 		}
-		avg := totalSale/float64(totalQuantity)
-		avgpnl := (stock.Price/avg - 1) * 100
-		str += fmt.Sprintf("Avg Sale Price  : %9.2f    [perf vs sale = %8.2f%% ]\n", avg, avgpnl)
-		lspnl := (stock.Price/lastSaleAmount - 1) * 100
-		str += fmt.Sprintf("Last Sale Price : %9.2f    [perf vs sale = %8.2f%% ]\n", lastSaleAmount, lspnl)
-
-
-	}
-	if len(stock.Sells) > 0 {
-		str += fmt.Sprintf("Realized Gains  : %9.2f\n", stock.TLR.RealizedGains)
-	}
-	keys := []string{}
-	for k, _ := range stock.TLR.DividendPerYear {
-		keys = append(keys, k)
+		fmt.Printf("%-12s %9.2f %s    %d days of history\n", result.Symbol, result.Quote.Price, result.Quote.Currency, len(result.History.Days))
 	}
-	str += fmt.Sprintf("Dividends total : %9.2f\n", stock.TLR.DividendPaid)
-	sort.Strings(keys)
-	for _, key := range keys {
-		value := stock.TLR.DividendPerYear[key]
-		str += fmt.Sprintf("Dividends " + key + "  : %9.2f\n", value)
-	}
-	if stock.TLR.DividendPaid > 0 {
-		str += fmt.Sprintf("Dividend Hikes  : %9d\n", stock.TLR.DividendHikes)
+	if failed > 0 {
+		os.Exit(1)
 	}
-	str += "\n=====\n=====\n\n"
-	return str
 }
 
-func printTimeline(stock Stock) {
-	fmt.Println("Stock : " + stock.Symbol)
-	// create a slice of keys strings
-	var keys []string
-	for k, _ := range stock.Timeline {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-	for _, key := range keys {
-		events := stock.Timeline[key]
-		fmt.Println("    " + key)
-		for _, event := range events {
-			fmt.Printf("         " + event.Type + " %d @ %.2f split %d:%d\n", event.Quantity, event.Amount, event.SplitTo, event.SplitFrom)
+func lots(args []string) {
+	var o options
+	flags := newFlagSet("lots", &o)
+	method := flags.String("method", "", "fifo, lifo or hifo (highest cost first), the lotMethod of the config by default")
+	flags.Parse(args)
+
+	symbols := o.selected(flags)
+	if len(symbols) == 0 {
+		log.Fatal("lots: give the symbols to print")
+	}
+	userInputs, marketData := loadMarketData(o)
+	if *method == "" {
+		*method = userInputs.LotMethod
+	}
+	if !portfolio.ValidLotMethod(*method) {
+		log.Fatal("lots: unknown method " + *method)
+	}
+	household := loadHousehold(o, flags, *method)
+	household.SetWashSales(userInputs.WashSales)
+	household.FetchMarketData(marketData, userInputs.Workers)
+	for _, name := range household.Names() {
+		if household.HasAccounts() {
+			fmt.Println("Account " + accountName(name))
+		}
+		stocks := household.Accounts[name]
+		for _, symbol := range symbols {
+			stock, isIn := stocks.Stocks[symbol]
+			if !isIn {
+				fmt.Println("Lots of " + symbol + " - not in the transactions")
+				continue
+			}
+			fmt.Print(portfolio.GetLotsString(stock, stocks.Now))
 		}
 	}
 }
 
-func ScreenStocks() {
-
-	stocks := []string{}
-	//stocks := []string{"AAPL", "AFN.TO", "AMZN", "ATD.B.TO", "BBD.B.TO", "BCE.TO", "BNS.TO", "CHB.TO", "CNR.TO", "COST", "CSH.UN.TO", "CTC.A.TO", "DIS", "DOL.TO", "ENB.TO", "ENF.TO", "FTS.TO", "GDXJ", "GE", "GOOG", "IPL.TO", "KMI", "MCD", "MRU.TO", "MTN", "NA.TO", "NFLX", "NVDA", "POW.TO", "QSR.TO", "RY.TO", "SHOP.TO", "SBUX", "SJ.TO", "SLF.TO", "TD.TO", "TWTR", "UNH", "V", "WEED.TO", "WSP.TO", "XBB.TO", "XHB.TO"}
-	for _, stock := range stocks {
-
-		now := time.Now()
-		currentData, historyData := GetWorldTradingData(stock)
-		price, _ := strconv.ParseFloat(currentData.Data[0].Price, 64)
-
-		threeDays 	:= calculateROISince(price, now.AddDate(0, 0, -3), historyData)
-		oneWeek 	:= calculateROISince(price, now.AddDate(0, 0, -7), historyData)
-		twoWeeks 	:= calculateROISince(price, now.AddDate(0, 0, -14), historyData)
-		oneMonth 	:= calculateROISince(price, now.AddDate(0, -1, 0), historyData)
-		twoMonths 	:= calculateROISince(price, now.AddDate(0, -2, 0), historyData)
-		sixMonth 	:= calculateROISince(price, now.AddDate(0, -6, 0), historyData)
-		oneyear 	:= calculateROISince(price, now.AddDate(-1, 0, 0), historyData)
-		twoyears 	:= calculateROISince(price, now.AddDate(-2, 0, 0), historyData)
+func form8949(args []string) {
+	var o options
+	flags := newFlagSet("8949", &o)
+	year := flags.String("year", strconv.Itoa(time.Now().Year()-1), "tax year")
+	flags.Parse(args)
+
+	userInputs, marketData := loadMarketData(o)
+	household := loadHousehold(o, flags, userInputs.LotMethod)
+	household.SetWashSales(userInputs.WashSales)
+	household.FetchMarketData(marketData, userInputs.Workers)
+
+	// the stocks in USD of the accounts filed with US taxes, GetWashSalesString leaves the others out
+	usStocks := []portfolio.Stock{}
+	for _, name := range household.Names() {
+		if !userInputs.WashSales(name) {
+			continue
+		}
+		stocks := household.Accounts[name]
+		for _, symbol := range stocks.Symbols() {
+			usStocks = append(usStocks, stocks.Stocks[symbol])
+		}
+	}
+	fmt.Print(portfolio.GetWashSalesString(usStocks, *year))
 
-		fmt.Printf(stock + "\t\t3 days:  %.2f%%\t\t1 week : %.2f%%\t\t2 weeks : %.2f%%\t\t1 month: %.2f%%\t\t2 months: %.2f%%\t\t6 months: %.2f%%\t\t1 year: %.2f%%\t\t2 years: %.2f%%\n", threeDays, oneWeek, twoWeeks, oneMonth, twoMonths, sixMonth, oneyear, twoyears)
+	if err := os.MkdirAll(o.output, 0755); err != nil {
+		log.Fatal(err)
 	}
+	writeOutput(filepath.Join(o.output, "form8949_"+*year+".csv"), portfolio.GetForm8949CSV(usStocks, *year))
 }
 
+func gains(args []string) {
+	var o options
+	flags := newFlagSet("gains", &o)
+	year := flags.String("year", "", "only this tax year")
+	flags.Parse(args)
 
-func main() {
-	
-	dir, err := os.Getwd()
+	// the quote gives the name and currency of the stocks
+	userInputs, marketData := loadMarketData(o)
+	household := loadHousehold(o, flags, "")
+	household.FetchMarketData(marketData, userInputs.Workers)
+
+	// only the taxable accounts have capital gains to report, in CAD at the rate of each trade
+	rates, err := provider.FXFromConfig(userInputs)
 	if err != nil {
 		log.Fatal(err)
 	}
-	var userInputs = utils.LoadConfiguration(dir + "/conf/config.json")
-	wtdToken = userInputs.WtdToken
-	useFilesFirst = userInputs.UseLocalFiles
-	
-	populateStocks()
+	results, missing := household.ComputeACB(portfolio.NewConverter("CAD", rates), userInputs.Taxable)
+	capitalGains := portfolio.CapitalGains(results)
+	fmt.Print(portfolio.GetCapitalGainsString(capitalGains, *year))
+	fmt.Print(portfolio.GetSuperficialLossesString(results, *year))
+	fmt.Print(portfolio.GetACBMissingString(missing))
 
-	fmt.Printf("\n\nCAD Dividends Last Month     %.2f\n", 	GlobalDividend1Month_CAD)
-	fmt.Printf("CAD Dividends Last 6 Months  %.2f\n", 	GlobalDividend6Months_CAD)
-	fmt.Printf("CAD Dividends Last Year      %.2f\n", 	GlobalDividend1Year_CAD)
+	if err := os.MkdirAll(o.output, 0755); err != nil {
+		log.Fatal(err)
+	}
+	written := make(map[string]bool)
+	for _, gain := range capitalGains {
+		if written[gain.Year] || *year != "" && gain.Year != *year {
+			continue
+		}
+		written[gain.Year] = true
+		writeOutput(filepath.Join(o.output, "schedule3_"+gain.Year+".csv"), portfolio.GetSchedule3CSV(capitalGains, gain.Year))
+	}
+}
 
+func value(args []string) {
+	var o options
+	flags := newFlagSet("value", &o)
+	flags.Parse(args)
 
-	fmt.Printf("\nUSD Dividends Last Month     %.2f\n", 	GlobalDividend1Month_USD)
-	fmt.Printf("USD Dividends Last 6 Months  %.2f\n", 	GlobalDividend6Months_USD)
-	fmt.Printf("USD Dividends Last Year      %.2f\n", 	GlobalDividend1Year_USD)
+	userInputs, marketData := loadMarketData(o)
+	household := loadHousehold(o, flags, "")
+	household.FetchMarketData(marketData, userInputs.Workers)
+	now := time.Now()
 
-	fmt.Println("\nCAD Dividend per year")
-	keys := []string{}
-	for k, _ := range GlobalDividendPerYear_CAD {
-		keys = append(keys, k)
+	if err := os.MkdirAll(o.output, 0755); err != nil {
+		log.Fatal(err)
 	}
-	sort.Strings(keys)
-	for _, key := range keys {
-		value := GlobalDividendPerYear_CAD[key]
-		fmt.Printf("    " + key + "  : %.2f\n", 	value)
+	total := household.Total()
+	fmt.Print(portfolio.GetTWRHeader())
+	for _, symbol := range total.Symbols() {
+		series := household.StockValueSeries(symbol)
+		if len(series) == 0 {
+			fmt.Printf("%-12s no history\n", symbol)
+			continue
+		}
+		fmt.Print(portfolio.GetTWRRow(symbol, portfolio.CalculateTWR(series, now)))
+		writeOutput(filepath.Join(o.output, "value_"+symbol+".csv"), portfolio.GetValueSeriesCSV(series, total.Stocks[symbol].Currency))
 	}
 
-	fmt.Println("\nUSD Dividend per year")
-	keys = []string{}
-	for k, _ := range GlobalDividendPerYear_USD {
-		keys = append(keys, k)
+	// the whole portfolio in each currency, and in the reportingCurrency
+	fmt.Println()
+	byCurrency := household.ValueSeries()
+	currencies := []string{}
+	for currency := range byCurrency {
+		currencies = append(currencies, currency)
 	}
-	sort.Strings(keys)
-	for _, key := range keys {
-		value := GlobalDividendPerYear_USD[key]
-		fmt.Printf("    " + key + "  : %.2f\n", 	value)
+	sort.Strings(currencies)
+	for _, currency := range currencies {
+		fmt.Print(portfolio.GetTWRRow("All "+currency, portfolio.CalculateTWR(byCurrency[currency], now)))
+		writeOutput(filepath.Join(o.output, "value_"+currency+".csv"), portfolio.GetValueSeriesCSV(byCurrency[currency], currency))
 	}
+	if userInputs.ReportingCurrency != "" {
+		converter := loadConverter(userInputs)
+		series := household.ValueSeriesIn(converter)
+		fmt.Print(portfolio.GetTWRRow("Total "+converter.Currency, portfolio.CalculateTWR(series, now)))
+		writeOutput(filepath.Join(o.output, "value_total_"+converter.Currency+".csv"), portfolio.GetValueSeriesCSV(series, converter.Currency))
+	}
+}
 
-	stock_summary_str := GetStockSummaryHeader()
-	active_stocks_str := ""
-	inactive_stocks_str := ""
+func validate(args []string) {
+	var o options
+	flags := newFlagSet("validate", &o)
+	flags.Parse(args)
 
-	keys = []string{}
-	for k, _ := range Stocks {
-		keys = append(keys, k)
+	userInputs := utils.LoadConfiguration(o.config)
+	accounts := []string{}
+	for _, account := range userInputs.Accounts {
+		accounts = append(accounts, account.Name)
 	}
-	sort.Strings(keys)
-
-	for _, k := range keys {
-		if Stocks[k].TLR.NumberOfShares > 0 {
-			stock_summary_str += GetStockSummaryRow(Stocks[k])
-			active_stocks_str += GetStockDetailsString(Stocks[k])
-		} else {
-			inactive_stocks_str += GetStockDetailsString(Stocks[k])
+	problems := portfolio.Validate(o.data, o.transactions, accounts)
+	if _, err := provider.FromConfig(userInputs); err != nil {
+		problems = append(problems, o.config+" - "+err.Error())
+	}
+	if !portfolio.ValidLotMethod(userInputs.LotMethod) {
+		problems = append(problems, o.config+" - unknown lotMethod "+userInputs.LotMethod)
+	}
+	if _, err := provider.FXFromConfig(userInputs); err != nil {
+		problems = append(problems, o.config+" - "+err.Error())
+	}
+	for _, account := range userInputs.Accounts {
+		known := false
+		for _, accountType := range utils.AccountTypes {
+			known = known || account.Type == accountType
+		}
+		if account.Name == "" || !known {
+			problems = append(problems, o.config+" - account "+account.Name+" needs a name and a type of "+strings.Join(utils.AccountTypes, ", "))
 		}
 	}
-
-	ioutil.WriteFile("output/stock_summary.csv", []byte(stock_summary_str), 0644)
-	ioutil.WriteFile("output/active_stock_details.txt", []byte(active_stocks_str), 0644)
-	ioutil.WriteFile("output/inactive_stock_details.txt", []byte(inactive_stocks_str), 0644)
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+	fmt.Println("ok")
 }
 
+func main() {
 
-
-
-
-
-
-///////////////
-//response, err := http.Get("https://www.alphavantage.co/query?function=TIME_SERIES_INTRADAY&symbol=AAPL&interval=1min&apikey=Z0SEJLQK6E3WDNFW")
-//if err != nil {
-//fmt.Printf("The HTTP request failed with error %s\n", err)
-//} else {
-//data, _ := ioutil.ReadAll(response.Body)
-//fmt.Println(string(data))
-//}
-
-
-
-
-//func calculateAveragePrice(stock Stock) float64 {
-//
-//	var totalCost = 0.0
-//	var totalQuantity = 0
-//
-//	for _, tx := range stock.Transactions {
-//		totalQuantity += tx.Quantity
-//		totalCost += (float64(tx.Quantity) * tx.Price)
-//	}
-//	avg := (totalCost/float64(totalQuantity))
-//	return avg
-//}
-
-//func calculatePNLPerBuy(stock Stock, currentPrice float64) ([]float64, float64) {
-//	var quantity int = 0
-//	var cost float64 = 0.0
-//	var pnls = []float64{}
-//	for _, tx := range stock.Transactions {
-//		quantity += tx.Quantity
-//		cost += tx.Price * float64(tx.Quantity)
-//		pnl := ((currentPrice/tx.Price) - 1.0) * 100.0
-//		pnls = append(pnls, pnl)
-//	}
-//	pnl := (currentPrice/(cost/float64(quantity)) - 1) * 100
-//	return pnls, pnl
-//}
-
-//// get it from the file
-//rawHistory, err := ioutil.ReadFile("/Users/kmorin/IdeaProjects/empty/first-go-prog/data/AAPL.json")
-//if err != nil {
-//	fmt.Println(err.Error())
-//	os.Exit(1)
-//}
-//json.Unmarshal(rawHistory, &historyData)
-
-//fmt.Println(currentData.Data[0].Name + " = " + currentData.Data[0].Price + "(day " + currentData.Data[0].ChangePct + "%%)")
-
-//fmt.Printf("Average price for AAPL = %.2f\n", calculateAveragePrice(allStocks["AAPL"]))
-//value, _ := strconv.ParseFloat(currentData.Data[0].Price, 64)
-//pnls, pnl := calculatePNLPerBuy(allStocks["AAPL"], value)
-//var i int =0
-//for _, pnl := range pnls {
-//	fmt.Printf("AAPL buy # %d [ %s : %d @ %.2f] => %.2f%%\n", i+1, allStocks["AAPL"].Buys[i].Date.Format("2006-01-02"), allStocks["AAPL"].Buys[i].Quantity, allStocks["AAPL"].Buys[i].Price, pnl)
-//	i++
-//}
-//fmt.Printf("AAPL AVG => %.2f%%\n", pnl)
-
+	commands := map[string]func([]string){
+		"report":   report,
+		"timeline": timeline,
+		"screen":   screen,
+		"fetch":    fetch,
+		"lots":     lots,
+		"8949":     form8949,
+		"gains":    gains,
+		"value":    value,
+		"validate": validate,
+	}
+
+	// no command, or only flags, is the report
+	args := os.Args[1:]
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "-help" {
+		args = append([]string{"report"}, args...)
+	}
+	command, isIn := commands[args[0]]
+	if !isIn {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command(args[1:])
+}
@@ -9,6 +9,59 @@ import (
 type Config struct {
     WtdToken string `json:"wtdToken"`
     UseLocalFiles bool `json:"useLocalFiles"`
+    Provider string `json:"provider"`
+    AlphaVantageKey string `json:"alphaVantageKey"`
+    AlphaVantageCallsPerMinute int `json:"alphaVantageCallsPerMinute"`
+    AlphaVantageCallsPerDay int `json:"alphaVantageCallsPerDay"`
+    AlphaVantageBaseURL string `json:"alphaVantageBaseURL"`
+    AlphaVantageOutputSize string `json:"alphaVantageOutputSize"`
+    CsvDirectory string `json:"csvDirectory"`
+    CacheDirectory string `json:"cacheDirectory"`
+    QuoteTTL string `json:"quoteTTL"`
+    HistoryTTL string `json:"historyTTL"`
+    Workers int `json:"workers"`
+    RequestsPerMinute map[string]int `json:"requestsPerMinute"`
+    Retries *int `json:"retries"`
+    RetryDelay string `json:"retryDelay"`
+    Watchlist []string `json:"watchlist"`
+    LotMethod string `json:"lotMethod"`
+    Accounts []Account `json:"accounts"`
+    ReportingCurrency string `json:"reportingCurrency"`
+    FxProvider string `json:"fxProvider"`
+    FxDirectory string `json:"fxDirectory"`
+    FxBaseURL string `json:"fxBaseURL"`
+    Benchmarks []string `json:"benchmarks"`
+}
+
+// Account is an account the transactions can name, Type is tfsa, rrsp, non-registered or margin.
+// WashSales is set for an account filed with US taxes, its lots apply the US wash sale rule
+type Account struct {
+    Name string `json:"name"`
+    Type string `json:"type"`
+    WashSales bool `json:"washSales"`
+}
+
+// AccountTypes are the account types a config can give, the gains of the registered ones are not taxed
+var AccountTypes = []string{"tfsa", "rrsp", "non-registered", "margin"}
+
+// Taxable tells if the gains of the account are taxed, an account the config does not define is
+func (config Config) Taxable(account string) bool {
+    for _, a := range config.Accounts {
+        if a.Name == account {
+            return a.Type != "tfsa" && a.Type != "rrsp"
+        }
+    }
+    return true
+}
+
+// WashSales tells if the account is filed with US taxes, an account the config does not define is not
+func (config Config) WashSales(account string) bool {
+    for _, a := range config.Accounts {
+        if a.Name == account {
+            return a.WashSales
+        }
+    }
+    return false
 }
 
 func LoadConfiguration(file string) Config {